@@ -0,0 +1,232 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Backup archives hold the config file as "config.json" and the disk cache
+// entries under "cache/". Memory and redis caches are not included.
+const (
+	backupConfigName = "config.json"
+	backupCacheDir   = "cache"
+)
+
+func runBackup(args []string) error {
+	if len(args) == 0 {
+		return errors.New(msg(msgBackupUsage))
+	}
+
+	switch args[0] {
+	case "create":
+		flags := flag.NewFlagSet("backup create", flag.ExitOnError)
+		out := flags.String("o", "film-cli-backup-"+time.Now().Format("20060102")+".tar.gz", "archive to write")
+		flags.Parse(args[1:])
+		return createBackup(*out, defaultConfigPath())
+
+	case "restore":
+		flags := flag.NewFlagSet("backup restore", flag.ExitOnError)
+		force := flags.Bool("force", false, "overwrite an existing config file")
+		flags.Parse(args[1:])
+		if flags.NArg() != 1 {
+			return errors.New(msg(msgRestoreUsage))
+		}
+		return restoreBackup(flags.Arg(0), defaultConfigPath(), *force)
+
+	default:
+		return errors.New(msg(msgUnknownBackupCmd, args[0]))
+	}
+}
+
+// diskCacheDir returns the directory of the configured disk cache, or "" if
+// the cache lives elsewhere.
+func diskCacheDir(cfg Config) string {
+	if cfg.Cache.Backend != "disk" {
+		return ""
+	}
+	if cfg.Cache.Dir != "" {
+		return cfg.Cache.Dir
+	}
+	return defaultCacheDir()
+}
+
+func createBackup(archivePath, configPath string) error {
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(archivePath)
+	if err != nil {
+		return fmt.Errorf("creating archive %q: %w", archivePath, err)
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+
+	if err := addFileToTar(tw, configPath, backupConfigName); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	if dir := diskCacheDir(cfg); dir != "" {
+		entries, err := os.ReadDir(dir)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("reading cache directory %q: %w", dir, err)
+		}
+		for _, e := range entries {
+			if e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+				continue
+			}
+			if err := addFileToTar(tw, filepath.Join(dir, e.Name()), path.Join(backupCacheDir, e.Name())); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("writing archive %q: %w", archivePath, err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("writing archive %q: %w", archivePath, err)
+	}
+	log.Printf("Wrote backup to %s", archivePath)
+	return f.Close()
+}
+
+func addFileToTar(tw *tar.Writer, src, name string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("reading %q: %w", src, err)
+	}
+
+	hdr := &tar.Header{
+		Name:    name,
+		Mode:    int64(info.Mode().Perm()),
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("archiving %q: %w", src, err)
+	}
+	if _, err := io.Copy(tw, f); err != nil {
+		return fmt.Errorf("archiving %q: %w", src, err)
+	}
+	return nil
+}
+
+func restoreBackup(archivePath, configPath string, force bool) error {
+	if configPath == "" {
+		return fmt.Errorf("cannot restore: no config path")
+	}
+
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return fmt.Errorf("opening archive %q: %w", archivePath, err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("reading archive %q: %w", archivePath, err)
+	}
+	tr := tar.NewReader(gz)
+
+	// The config is restored first so cache entries land in the directory
+	// the restored config points at. An archive without a config leaves the
+	// existing one alone.
+	var cacheDir string
+	cacheChecked := false
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("reading archive %q: %w", archivePath, err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		var dst string
+		switch dir, name := path.Split(hdr.Name); {
+		case hdr.Name == backupConfigName:
+			if _, err := os.Stat(configPath); err == nil && !force {
+				return fmt.Errorf("config %q already exists; use -force to overwrite", configPath)
+			}
+			dst = configPath
+		case dir == backupCacheDir+"/" && name != "" && !strings.HasPrefix(name, "."):
+			if !cacheChecked {
+				cfg, err := LoadConfig(configPath)
+				if err != nil {
+					return err
+				}
+				if cacheDir = diskCacheDir(cfg); cacheDir == "" {
+					log.Printf("Cache backend %q is not disk; skipping cached entries", cfg.Cache.Backend)
+				}
+				cacheChecked = true
+			}
+			if cacheDir == "" {
+				continue
+			}
+			dst = filepath.Join(cacheDir, name)
+		default:
+			log.Printf("Skipping unexpected archive entry %q", hdr.Name)
+			continue
+		}
+
+		if err := writeRestored(dst, tr, os.FileMode(hdr.Mode).Perm()); err != nil {
+			return err
+		}
+	}
+	log.Printf("Restored backup from %s", archivePath)
+	return nil
+}
+
+// writeRestored writes r to dst through a temporary file in the same
+// directory, so an interrupted restore leaves the old file in place.
+func writeRestored(dst string, r io.Reader, perm os.FileMode) error {
+	dir := filepath.Dir(dst)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("creating directory for %q: %w", dst, err)
+	}
+	f, err := os.CreateTemp(dir, ".restore-*")
+	if err != nil {
+		return fmt.Errorf("restoring %q: %w", dst, err)
+	}
+	defer os.Remove(f.Name()) // fails harmlessly once renamed
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return fmt.Errorf("restoring %q: %w", dst, err)
+	}
+	if err := f.Chmod(perm); err != nil {
+		f.Close()
+		return fmt.Errorf("restoring %q: %w", dst, err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("restoring %q: %w", dst, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("restoring %q: %w", dst, err)
+	}
+	if err := os.Rename(f.Name(), dst); err != nil {
+		return fmt.Errorf("restoring %q: %w", dst, err)
+	}
+	return nil
+}
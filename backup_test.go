@@ -0,0 +1,122 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBackupRestoreRoundTrip(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	srcCache := filepath.Join(src, "cache")
+	configPath := filepath.Join(src, "config.json")
+	writeFile(t, configPath, `{"cache": {"backend": "disk", "dir": "`+filepath.ToSlash(srcCache)+`"}}`)
+	writeFile(t, filepath.Join(srcCache, "entry"), "master playlist")
+	writeFile(t, filepath.Join(srcCache, ".last-failure.json"), "{}")
+
+	archive := filepath.Join(src, "backup.tar.gz")
+	if err := createBackup(archive, configPath); err != nil {
+		t.Fatal(err)
+	}
+	entries := readTarGz(t, archive)
+	if _, ok := entries["cache/.last-failure.json"]; ok || entries["cache/entry"] != "master playlist" {
+		t.Errorf("archive entries = %v, want the cache entry without dotfiles", entries)
+	}
+
+	// Restore onto a fresh machine: the cache entry is gone and the config
+	// already exists until it is removed.
+	if err := os.RemoveAll(srcCache); err != nil {
+		t.Fatal(err)
+	}
+	restoredConfig := filepath.Join(dst, "config.json")
+	writeFile(t, restoredConfig, "{}")
+	if err := restoreBackup(archive, restoredConfig, false); err == nil {
+		t.Fatal("restore overwrote an existing config without -force")
+	}
+	if err := restoreBackup(archive, restoredConfig, true); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, restoredConfig); got != readFile(t, configPath) {
+		t.Errorf("restored config = %q", got)
+	}
+	if got := readFile(t, filepath.Join(srcCache, "entry")); got != "master playlist" {
+		t.Errorf("restored cache entry = %q", got)
+	}
+	if leftovers, _ := filepath.Glob(filepath.Join(dst, ".restore-*")); len(leftovers) > 0 {
+		t.Errorf("temporary files left behind: %v", leftovers)
+	}
+}
+
+func TestRestoreWithoutConfig(t *testing.T) {
+	dir := t.TempDir()
+	cacheDir := filepath.Join(dir, "cache")
+	configPath := filepath.Join(dir, "config.json")
+	writeFile(t, configPath, `{"cache": {"backend": "disk", "dir": "`+filepath.ToSlash(cacheDir)+`"}}`)
+
+	archive := filepath.Join(dir, "cache-only.tar.gz")
+	writeTarGz(t, archive, map[string]string{"cache/entry": "cached"})
+	if err := restoreBackup(archive, configPath, false); err != nil {
+		t.Fatalf("restore of an archive without a config refused: %v", err)
+	}
+	if got := readFile(t, filepath.Join(cacheDir, "entry")); got != "cached" {
+		t.Errorf("restored cache entry = %q", got)
+	}
+}
+
+func TestRestoreSkipsCacheForOtherBackends(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.json")
+	writeFile(t, configPath, `{"cache": {"backend": "memory", "dir": "`+filepath.ToSlash(dir)+`"}}`)
+
+	archive := filepath.Join(dir, "cache-only.tar.gz")
+	writeTarGz(t, archive, map[string]string{"cache/entry": "cached"})
+	if err := restoreBackup(archive, configPath, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "entry")); err == nil {
+		t.Error("restored a cache entry for the memory backend")
+	}
+}
+
+func writeFile(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func writeTarGz(t *testing.T, path string, entries map[string]string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	for name, data := range entries {
+		if err := addBytesToTar(tw, name, []byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
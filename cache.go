@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Cache stores opaque values by key. Implementations must be safe for
+// concurrent use; a miss is reported as ok == false with a nil error.
+type Cache interface {
+	Get(key string) (value []byte, ok bool, err error)
+	Set(key string, value []byte, ttl time.Duration) error
+}
+
+// CacheConfig selects and configures the cache backend.
+type CacheConfig struct {
+	Backend string      `json:"backend"` // "memory", "disk" or "redis"
+	Dir     string      `json:"dir"`     // disk backend directory
+	TTL     Duration    `json:"ttl"`
+	Redis   RedisConfig `json:"redis"`
+}
+
+// RedisConfig is the connection info for the redis backend.
+type RedisConfig struct {
+	Addr     string `json:"addr"`
+	Password string `json:"password"`
+	DB       int    `json:"db"`
+}
+
+// defaultCacheDir returns film-cli's directory in the user cache directory.
+func defaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "film-cli")
+	}
+	return filepath.Join(dir, "film-cli")
+}
+
+// Open builds the configured backend.
+func (c CacheConfig) Open() (Cache, error) {
+	switch c.Backend {
+	case "", "memory":
+		return newMemoryCache(maxMemoryCacheEntries), nil
+	case "disk":
+		dir := c.Dir
+		if dir == "" {
+			dir = defaultCacheDir()
+		}
+		return newDiskCache(dir)
+	case "redis":
+		if c.Redis.Addr == "" {
+			return nil, fmt.Errorf("redis cache backend requires cache.redis.addr")
+		}
+		return newRedisCache(c.Redis), nil
+	default:
+		return nil, fmt.Errorf("unknown cache backend %q", c.Backend)
+	}
+}
+
+// maxMemoryCacheEntries bounds the in-memory cache.
+const maxMemoryCacheEntries = 64
+
+type memoryEntry struct {
+	value   []byte
+	expires time.Time // zero means no expiry
+}
+
+// memoryCache is a small bounded cache that evicts the oldest entry first.
+type memoryCache struct {
+	mu      sync.Mutex
+	max     int
+	entries map[string]memoryEntry
+	order   []string // insertion order, oldest first
+}
+
+func newMemoryCache(max int) *memoryCache {
+	return &memoryCache{max: max, entries: map[string]memoryEntry{}}
+}
+
+func (c *memoryCache) Get(key string) ([]byte, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok || (!e.expires.IsZero() && time.Now().After(e.expires)) {
+		return nil, false, nil
+	}
+	return e.value, true, nil
+}
+
+func (c *memoryCache) Set(key string, value []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; !ok {
+		c.order = append(c.order, key)
+		if len(c.order) > c.max {
+			delete(c.entries, c.order[0])
+			c.order = c.order[1:]
+		}
+	}
+	e := memoryEntry{value: value}
+	if ttl > 0 {
+		e.expires = time.Now().Add(ttl)
+	}
+	c.entries[key] = e
+	return nil
+}
+
+// shared cache, replaced from the config file in main
+var cache Cache = newMemoryCache(maxMemoryCacheEntries)
+
+// cacheTTL is how long cached entries are kept; zero keeps them until evicted.
+var cacheTTL time.Duration
+
+// playlistEntry is a cached playlist body with the validators the server sent for it.
+type playlistEntry struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	Body         string `json:"body"`
+}
+
+func cachedPlaylist(url string) (playlistEntry, bool) {
+	var e playlistEntry
+	data, ok, err := cache.Get("playlist:" + url)
+	if err != nil {
+		log.Printf("Cache lookup failed for %s: %v", url, err)
+		return e, false
+	}
+	if !ok {
+		return e, false
+	}
+	if err := json.Unmarshal(data, &e); err != nil {
+		log.Printf("Ignoring corrupt cache entry for %s: %v", url, err)
+		return e, false
+	}
+	return e, true
+}
+
+func storePlaylist(url string, e playlistEntry) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	if err := cache.Set("playlist:"+url, data, cacheTTL); err != nil {
+		log.Printf("Cache store failed for %s: %v", url, err)
+	}
+}
+
+// fetchPlaylist GETs a playlist, revalidating any cached copy with
+// If-None-Match / If-Modified-Since and reusing it on 304 Not Modified.
+func fetchPlaylist(url string) (string, error) {
+	ctx, cancel := stepContext(timeouts.Playlist)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return "", fmt.Errorf("creating request for playlist %q: %w", url, err)
+	}
+
+	for k, v := range vidsrc.cdnHeaders() {
+		req.Header.Set(k, v)
+	}
+
+	cached, ok := cachedPlaylist(url)
+	if ok {
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("fetching playlist %q: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if ok && resp.StatusCode == http.StatusNotModified {
+		log.Printf("Playlist not modified, using cached copy: %s", url)
+		return cached.Body, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", &statusError{Code: resp.StatusCode, What: "playlist", URL: url}
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("reading playlist %q: %w", url, err)
+	}
+
+	entry := playlistEntry{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		Body:         string(body),
+	}
+	if entry.ETag != "" || entry.LastModified != "" {
+		storePlaylist(url, entry)
+	}
+	return entry.Body, nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDiskCache(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "cache")
+	c, err := newDiskCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok, err := c.Get("missing"); ok || err != nil {
+		t.Errorf("Get(missing) = %v, %v, want a miss", ok, err)
+	}
+	if err := c.Set("key", []byte("value"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok, err := c.Get("key"); !ok || err != nil || string(v) != "value" {
+		t.Errorf("Get(key) = %q, %v, %v, want value", v, ok, err)
+	}
+
+	// A second handle on the same directory sees the entry.
+	other, err := newDiskCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok, _ := other.Get("key"); !ok || string(v) != "value" {
+		t.Errorf("Get(key) from a new handle = %q, %v", v, ok)
+	}
+
+	if leftovers, _ := filepath.Glob(filepath.Join(dir, ".tmp-*")); len(leftovers) > 0 {
+		t.Errorf("temporary files left behind: %v", leftovers)
+	}
+}
+
+func TestDiskCacheExpiry(t *testing.T) {
+	c, err := newDiskCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Set("key", []byte("value"), time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	if _, ok, err := c.Get("key"); ok || err != nil {
+		t.Errorf("Get(key) after expiry = %v, %v, want a miss", ok, err)
+	}
+	if _, err := os.Stat(c.path("key")); !os.IsNotExist(err) {
+		t.Errorf("expired entry still on disk: %v", err)
+	}
+}
+
+func TestDiskCacheCorruptEntry(t *testing.T) {
+	c, err := newDiskCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(c.path("key"), []byte("not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err := c.Get("key"); ok || err == nil {
+		t.Errorf("Get(corrupt) = %v, %v, want an error", ok, err)
+	}
+}
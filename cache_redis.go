@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// redisKeyPrefix namespaces film-cli keys in a shared redis database.
+const redisKeyPrefix = "film-cli:"
+
+// redisCache is a minimal RESP client supporting only what Cache needs
+// (AUTH, SELECT, GET, SET PX), so no redis dependency is required.
+type redisCache struct {
+	cfg RedisConfig
+
+	mu   sync.Mutex
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func newRedisCache(cfg RedisConfig) *redisCache {
+	return &redisCache{cfg: cfg}
+}
+
+func (c *redisCache) Get(key string) ([]byte, bool, error) {
+	reply, err := c.do("GET", redisKeyPrefix+key)
+	if err != nil {
+		return nil, false, err
+	}
+	if reply == nil {
+		return nil, false, nil
+	}
+	return reply, true, nil
+}
+
+func (c *redisCache) Set(key string, value []byte, ttl time.Duration) error {
+	args := []string{"SET", redisKeyPrefix + key, string(value)}
+	if ttl > 0 {
+		args = append(args, "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	}
+	_, err := c.do(args...)
+	return err
+}
+
+// do sends one command and returns its reply, reconnecting once if the
+// pooled connection has gone away.
+func (c *redisCache) do(args ...string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for attempt := 0; ; attempt++ {
+		if c.conn == nil {
+			if err := c.connect(); err != nil {
+				return nil, err
+			}
+		}
+		reply, err := c.roundTrip(args)
+		var rerr redisError
+		if err == nil || errors.As(err, &rerr) {
+			return reply, err
+		}
+		c.conn.Close()
+		c.conn = nil
+		if attempt > 0 {
+			return nil, fmt.Errorf("redis %s: %w", args[0], err)
+		}
+	}
+}
+
+func (c *redisCache) connect() error {
+	conn, err := net.DialTimeout("tcp", c.cfg.Addr, 5*time.Second)
+	if err != nil {
+		return fmt.Errorf("connecting to redis %q: %w", c.cfg.Addr, err)
+	}
+	c.conn = conn
+	c.r = bufio.NewReader(conn)
+
+	if c.cfg.Password != "" {
+		if _, err := c.roundTrip([]string{"AUTH", c.cfg.Password}); err != nil {
+			c.conn.Close()
+			c.conn = nil
+			return fmt.Errorf("authenticating to redis: %w", err)
+		}
+	}
+	if c.cfg.DB != 0 {
+		if _, err := c.roundTrip([]string{"SELECT", strconv.Itoa(c.cfg.DB)}); err != nil {
+			c.conn.Close()
+			c.conn = nil
+			return fmt.Errorf("selecting redis db %d: %w", c.cfg.DB, err)
+		}
+	}
+	return nil
+}
+
+func (c *redisCache) roundTrip(args []string) ([]byte, error) {
+	c.conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	buf := fmt.Appendf(nil, "*%d\r\n", len(args))
+	for _, a := range args {
+		buf = fmt.Appendf(buf, "$%d\r\n%s\r\n", len(a), a)
+	}
+	if _, err := c.conn.Write(buf); err != nil {
+		return nil, err
+	}
+	return c.readReply()
+}
+
+// redisError is an error reply from the server, as opposed to a transport failure.
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+// readReply parses a single RESP reply. Nil bulk strings return (nil, nil).
+func (c *redisCache) readReply() ([]byte, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 {
+		return nil, fmt.Errorf("malformed redis reply %q", line)
+	}
+	line = line[:len(line)-2]
+
+	switch line[0] {
+	case '+', ':':
+		return []byte(line[1:]), nil
+	case '-':
+		return nil, redisError(line[1:])
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("malformed redis bulk length %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, data); err != nil {
+			return nil, err
+		}
+		return data[:n], nil
+	default:
+		return nil, fmt.Errorf("unsupported redis reply type %q", line[0])
+	}
+}
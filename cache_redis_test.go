@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis serves RESP on a local port, answering each command with the
+// raw reply reply returns, one command at a time. It records the commands
+// it received.
+type fakeRedis struct {
+	addr string
+
+	mu       sync.Mutex
+	commands []string
+}
+
+func newFakeRedis(t *testing.T, reply func(args []string) string) *fakeRedis {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	f := &fakeRedis{addr: ln.Addr().String()}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn, reply)
+		}
+	}()
+	return f
+}
+
+func (f *fakeRedis) serve(conn net.Conn, reply func([]string) string) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		f.mu.Lock()
+		f.commands = append(f.commands, strings.Join(args, " "))
+		out := reply(args)
+		f.mu.Unlock()
+		if out == "" { // drop the connection
+			return
+		}
+		io.WriteString(conn, out)
+	}
+}
+
+func (f *fakeRedis) received() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.commands...)
+}
+
+// readCommand reads one RESP array of bulk strings.
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil {
+		return nil, fmt.Errorf("bad command header %q", line)
+	}
+	args := make([]string, n)
+	for i := range args {
+		if line, err = r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "$")))
+		if err != nil {
+			return nil, fmt.Errorf("bad bulk header %q", line)
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		args[i] = string(data[:size])
+	}
+	return args, nil
+}
+
+func TestRedisReplies(t *testing.T) {
+	f := newFakeRedis(t, func(args []string) string {
+		switch strings.Join(args, " ") {
+		case "GET film-cli:hit":
+			return "$5\r\nvalue\r\n"
+		case "GET film-cli:miss":
+			return "$-1\r\n"
+		case "INCR counter":
+			return ":42\r\n"
+		case "SET film-cli:key value PX 60000":
+			return "+OK\r\n"
+		}
+		return "-ERR unknown command '" + args[0] + "'\r\n"
+	})
+	c := newRedisCache(RedisConfig{Addr: f.addr})
+
+	if v, ok, err := c.Get("hit"); !ok || err != nil || string(v) != "value" {
+		t.Errorf("Get(hit) = %q, %v, %v, want the bulk reply", v, ok, err)
+	}
+	if v, ok, err := c.Get("miss"); ok || err != nil || v != nil {
+		t.Errorf("Get(miss) = %q, %v, %v, want a nil reply as a miss", v, ok, err)
+	}
+	if v, err := c.do("INCR", "counter"); err != nil || string(v) != "42" {
+		t.Errorf("INCR = %q, %v, want the integer reply", v, err)
+	}
+	if err := c.Set("key", []byte("value"), time.Minute); err != nil {
+		t.Errorf("Set: %v", err)
+	}
+
+	_, err := c.do("BOGUS")
+	var rerr redisError
+	if !errors.As(err, &rerr) || !strings.Contains(err.Error(), "unknown command") {
+		t.Errorf("BOGUS error = %v, want the server's error reply", err)
+	}
+	// An error reply is not a transport failure: the connection is kept.
+	if _, _, err := c.Get("hit"); err != nil {
+		t.Errorf("Get after an error reply: %v", err)
+	}
+}
+
+func TestRedisAuthSelectAndReconnect(t *testing.T) {
+	dropped := false
+	f := newFakeRedis(t, func(args []string) string {
+		switch args[0] {
+		case "AUTH", "SELECT":
+			return "+OK\r\n"
+		case "GET":
+			if !dropped {
+				dropped = true
+				return "" // the pooled connection goes away
+			}
+			return "$2\r\nok\r\n"
+		}
+		return "-ERR unexpected\r\n"
+	})
+	c := newRedisCache(RedisConfig{Addr: f.addr, Password: "secret", DB: 2})
+
+	if v, ok, err := c.Get("key"); !ok || err != nil || string(v) != "ok" {
+		t.Fatalf("Get = %q, %v, %v, want a reply after reconnecting", v, ok, err)
+	}
+	want := []string{"AUTH secret", "SELECT 2", "GET film-cli:key", "AUTH secret", "SELECT 2", "GET film-cli:key"}
+	if got := f.received(); strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Errorf("commands = %q, want %q", got, want)
+	}
+}
+
+func TestRedisMalformedReply(t *testing.T) {
+	f := newFakeRedis(t, func([]string) string { return "?what\r\n" })
+	c := newRedisCache(RedisConfig{Addr: f.addr})
+	if _, _, err := c.Get("key"); err == nil {
+		t.Error("Get succeeded on a malformed reply")
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFetchPlaylistRevalidates(t *testing.T) {
+	const body = "#EXTM3U\n"
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inm := r.Header.Get("If-None-Match")
+		requests = append(requests, inm)
+		if inm == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		io.WriteString(w, body)
+	}))
+	defer srv.Close()
+	swapClient(t, srv.Client())
+
+	for i := 0; i < 2; i++ {
+		got, err := fetchPlaylist(srv.URL + "/master.m3u8")
+		if err != nil {
+			t.Fatalf("fetch %d: %v", i+1, err)
+		}
+		if got != body {
+			t.Errorf("fetch %d = %q, want %q", i+1, got, body)
+		}
+	}
+	// The second GET carries the stored ETag and is answered with 304, so
+	// the body is only sent once.
+	if want := []string{"", `"v1"`}; len(requests) != 2 || requests[0] != want[0] || requests[1] != want[1] {
+		t.Errorf("If-None-Match headers = %q, want %q", requests, want)
+	}
+}
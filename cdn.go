@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+)
+
+// HostFilter decides which CDN hosts variants and their audio and subtitle
+// renditions may be played from. A pattern matches the host itself and any
+// subdomain of it.
+type HostFilter struct {
+	Block []string `json:"block"` // hosts to skip
+	Allow []string `json:"allow"` // if set, only these hosts are used
+}
+
+// cdn filter, replaced from the config file in main
+var cdnFilter HostFilter
+
+func hostMatches(host, pattern string) bool {
+	pattern = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(pattern), "."))
+	host = strings.ToLower(host)
+	return pattern != "" && (host == pattern || strings.HasSuffix(host, "."+pattern))
+}
+
+// Allowed reports whether rawURL's host passes the filter.
+func (f HostFilter) Allowed(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	host := u.Hostname()
+	for _, p := range f.Block {
+		if hostMatches(host, p) {
+			return false
+		}
+	}
+	if len(f.Allow) == 0 {
+		return true
+	}
+	for _, p := range f.Allow {
+		if hostMatches(host, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterVariants drops the variants on hosts the filter rejects.
+func (f HostFilter) filterVariants(variants []StreamVariant) ([]StreamVariant, error) {
+	var kept []StreamVariant
+	for _, v := range variants {
+		if f.Allowed(v.URL) {
+			kept = append(kept, v)
+		} else {
+			log.Printf("Skipping variant on filtered CDN host: %s", v.URL)
+		}
+	}
+	if len(kept) == 0 && len(variants) > 0 {
+		return nil, fmt.Errorf("all %d stream variants are on blocked or non-allowed CDN hosts", len(variants))
+	}
+	return kept, nil
+}
+
+// filterRenditions drops the renditions on hosts the filter rejects. Muxed
+// renditions, which have no URL of their own, are kept. Unlike variants,
+// losing every rendition is not an error: the variant's own tracks remain.
+func (f HostFilter) filterRenditions(renditions []Rendition) []Rendition {
+	var kept []Rendition
+	for _, r := range renditions {
+		if r.URL == "" || f.Allowed(r.URL) {
+			kept = append(kept, r)
+		} else {
+			log.Printf("Skipping %s rendition on filtered CDN host: %s", r.Type, r.URL)
+		}
+	}
+	return kept
+}
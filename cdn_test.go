@@ -0,0 +1,47 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHostFilter(t *testing.T) {
+	f := HostFilter{Block: []string{"slow.example.test"}, Allow: []string{".example.test"}}
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://cdn.example.test/pl/1080/index.m3u8", true},
+		{"https://slow.example.test/pl/index.m3u8", false},
+		{"https://edge1.slow.example.test/pl/index.m3u8", false},
+		{"https://example.test.evil.test/pl/index.m3u8", false},
+		{"https://other.test/pl/index.m3u8", false},
+	}
+	for _, tt := range tests {
+		if got := f.Allowed(tt.url); got != tt.want {
+			t.Errorf("Allowed(%q) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+
+	if !(HostFilter{}).Allowed("https://anything.test/x.m3u8") {
+		t.Error("empty filter rejected a host")
+	}
+
+	variants := []StreamVariant{{URL: "https://slow.example.test/a.m3u8"}}
+	if _, err := f.filterVariants(variants); err == nil {
+		t.Error("filterVariants succeeded with every variant blocked")
+	}
+
+	renditions := []Rendition{
+		{Type: "AUDIO", Name: "muxed"},
+		{Type: "AUDIO", Name: "kept", URL: "https://cdn.example.test/audio/en.m3u8"},
+		{Type: "SUBTITLES", Name: "blocked", URL: "https://slow.example.test/subs/en.m3u8"},
+	}
+	var names []string
+	for _, r := range f.filterRenditions(renditions) {
+		names = append(names, r.Name)
+	}
+	if strings.Join(names, ",") != "muxed,kept" {
+		t.Errorf("filterRenditions kept %q, want the muxed and allowed renditions", names)
+	}
+}
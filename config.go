@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Config holds the user settings read from the config file.
+type Config struct {
+	HTTP     HTTPConfig  `json:"http"`
+	Timeouts Timeouts    `json:"timeouts"`
+	Cache    CacheConfig `json:"cache"`
+	Language string      `json:"language"` // output language, e.g. "de"; defaults to LANG
+	Player   string      `json:"player"`   // player for -play; found on PATH when empty
+
+	Providers map[string]ProviderConfig `json:"providers"` // keyed by provider name, e.g. "vidsrc"
+	CDN       HostFilter                `json:"cdn"`
+	Status    StatusConfig              `json:"status"` // opt-in provider status sharing
+
+	Profiles map[string]Profile `json:"profiles"` // quality profiles for -profile, keyed by name
+}
+
+// ProviderConfig holds the hosts and request headers one provider needs.
+// Empty fields fall back to the built-in defaults for that provider, so a
+// config only has to name what changed when the hosts rotate.
+type ProviderConfig struct {
+	EmbedBase  string            `json:"embed_base"`  // embed page host, e.g. https://vidsrc-embed.ru
+	PlayerBase string            `json:"player_base"` // host serving the ProRCP page and decoder script
+	Referer    string            `json:"referer"`     // sent on player-host requests
+	Origin     string            `json:"origin"`      // sent on player-host requests when set
+	Headers    map[string]string `json:"headers"`     // extra headers sent on every request to the provider
+
+	// Placeholders fills in the {v1}-style host placeholders of decoded
+	// stream URLs, e.g. {"v1": "example.com"}.
+	Placeholders map[string]string `json:"placeholders"`
+}
+
+// defaultProviders returns the built-in provider settings.
+func defaultProviders() map[string]ProviderConfig {
+	return map[string]ProviderConfig{
+		"vidsrc": {
+			EmbedBase:  "https://vidsrc-embed.ru",
+			PlayerBase: "https://cloudnestra.com",
+			Referer:    "https://cloudnestra.com",
+			// No Placeholders: the hosts behind {v1} and the like rotate
+			// too often to ship, so users configure the current ones.
+		},
+	}
+}
+
+// withDefaults fills the empty fields of p from def. Headers and placeholders
+// are merged, with the ones in p winning.
+func (p ProviderConfig) withDefaults(def ProviderConfig) ProviderConfig {
+	if p.EmbedBase == "" {
+		p.EmbedBase = def.EmbedBase
+	}
+	if p.PlayerBase == "" {
+		p.PlayerBase = def.PlayerBase
+	}
+	if p.Referer == "" {
+		p.Referer = def.Referer
+	}
+	if p.Origin == "" {
+		p.Origin = def.Origin
+	}
+	p.Headers = mergeStrings(def.Headers, p.Headers)
+	p.Placeholders = mergeStrings(def.Placeholders, p.Placeholders)
+	return p
+}
+
+// mergeStrings returns base overlaid with override, or override unchanged
+// when base is empty.
+func mergeStrings(base, override map[string]string) map[string]string {
+	if len(base) == 0 {
+		return override
+	}
+	merged := map[string]string{}
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range override {
+		merged[k] = v
+	}
+	return merged
+}
+
+// cdnHeaders returns the headers for playlist and segment requests. CDNs
+// that check Referer increasingly check Origin too, so when none is
+// configured it is derived from the referer.
+func (p ProviderConfig) cdnHeaders() map[string]string {
+	h := map[string]string{}
+	if p.Referer == "" {
+		return h
+	}
+	h["Referer"] = p.Referer
+	if origin := p.Origin; origin != "" {
+		h["Origin"] = origin
+	} else if origin := originOf(p.Referer); origin != "" {
+		h["Origin"] = origin
+	}
+	return h
+}
+
+// originOf returns the scheme://host origin of rawURL, or "" if it has none.
+func originOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// requestHeaders returns the headers for a request to the provider; the
+// referer and origin are only sent to the player host.
+func (p ProviderConfig) requestHeaders(playerHost bool) map[string]string {
+	h := map[string]string{}
+	for k, v := range p.Headers {
+		h[k] = v
+	}
+	if playerHost {
+		if p.Referer != "" {
+			h["Referer"] = p.Referer
+		}
+		if p.Origin != "" {
+			h["Origin"] = p.Origin
+		}
+	}
+	return h
+}
+
+// Timeouts bounds each step of the pipeline separately, so a slow proxy can
+// be given time on the heavy pages without waiting as long on quick ones.
+type Timeouts struct {
+	Embed      Duration `json:"embed"`       // vidsrc embed page
+	DecodePage Duration `json:"decode_page"` // RCP and ProRCP pages and the decoder script
+	Playlist   Duration `json:"playlist"`    // master and media playlists
+}
+
+// HTTPConfig tunes the shared HTTP client and its connection pool.
+type HTTPConfig struct {
+	MaxIdleConns        int      `json:"max_idle_conns"`
+	MaxIdleConnsPerHost int      `json:"max_idle_conns_per_host"`
+	MaxConnsPerHost     int      `json:"max_conns_per_host"`
+	IdleConnTimeout     Duration `json:"idle_conn_timeout"`
+	HTTP2               bool     `json:"http2"`
+}
+
+// Duration is a time.Duration that reads and writes as a string like "10s".
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"10s\": %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("parsing duration %q: %w", s, err)
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// DefaultConfig returns the settings used when no config file is present.
+func DefaultConfig() Config {
+	return Config{
+		HTTP: HTTPConfig{
+			MaxIdleConns: 100,
+			// net/http keeps only 2 idle connections per host by default,
+			// which forces a new TLS handshake for most requests in a burst.
+			MaxIdleConnsPerHost: 16,
+			IdleConnTimeout:     Duration(90 * time.Second),
+			HTTP2:               true,
+		},
+		Timeouts: Timeouts{
+			Embed:      Duration(5 * time.Second),
+			DecodePage: Duration(15 * time.Second),
+			Playlist:   Duration(10 * time.Second),
+		},
+		Cache: CacheConfig{
+			Backend: "memory",
+			TTL:     Duration(6 * time.Hour),
+		},
+		Providers: defaultProviders(),
+	}
+}
+
+// defaultConfigPath returns $FILM_CLI_CONFIG, or config.json in the user config directory.
+func defaultConfigPath() string {
+	if p := os.Getenv("FILM_CLI_CONFIG"); p != "" {
+		return p
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "film-cli", "config.json")
+}
+
+// LoadConfig reads the config file at path on top of DefaultConfig.
+// A missing file is not an error; the defaults are returned instead.
+func LoadConfig(path string) (Config, error) {
+	cfg := DefaultConfig()
+	if path == "" {
+		return cfg, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, fmt.Errorf("reading config %q: %w", path, err)
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("parsing config %q: %w", path, err)
+	}
+	if cfg.Providers == nil {
+		cfg.Providers = map[string]ProviderConfig{}
+	}
+	for name, def := range defaultProviders() {
+		cfg.Providers[name] = cfg.Providers[name].withDefaults(def)
+	}
+	return cfg, nil
+}
+
+// NewClient builds an HTTP client whose transport uses these pool settings.
+func (c HTTPConfig) NewClient() *http.Client {
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:          c.MaxIdleConns,
+		MaxIdleConnsPerHost:   c.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       c.MaxConnsPerHost,
+		IdleConnTimeout:       time.Duration(c.IdleConnTimeout),
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		ForceAttemptHTTP2:     c.HTTP2,
+	}
+	if !c.HTTP2 {
+		// A non-nil, empty map stops the transport from negotiating h2.
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+
+	// No client-wide timeout: each pipeline step sets its own, see Timeouts.
+	return &http.Client{Transport: transport}
+}
+
+// stepContext returns a context that expires after d; zero means no limit.
+func stepContext(d Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), time.Duration(d))
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadConfigProviderDefaults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	data := `{"providers": {"vidsrc": {"player_base": "https://player.example.test", "headers": {"User-Agent": "film-cli"}, "placeholders": {"v1": "example.test"}}}}`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := cfg.Providers["vidsrc"]
+	if p.PlayerBase != "https://player.example.test" {
+		t.Errorf("PlayerBase = %q, want the configured host", p.PlayerBase)
+	}
+	if def := defaultProviders()["vidsrc"]; p.EmbedBase != def.EmbedBase || p.Referer != def.Referer {
+		t.Errorf("unset fields were not filled from the defaults: %+v", p)
+	}
+	if p.Placeholders["v1"] != "example.test" {
+		t.Errorf("Placeholders = %v, want the configured v1 host", p.Placeholders)
+	}
+
+	h := p.requestHeaders(true)
+	if h["Referer"] != p.Referer || h["User-Agent"] != "film-cli" {
+		t.Errorf("requestHeaders(true) = %v", h)
+	}
+	if h := p.requestHeaders(false); h["Referer"] != "" || h["User-Agent"] != "film-cli" {
+		t.Errorf("requestHeaders(false) = %v, want only the extra headers", h)
+	}
+}
+
+func TestCDNHeadersDeriveOrigin(t *testing.T) {
+	p := ProviderConfig{Referer: "https://cloudnestra.com/prorcp/abc"}
+	h := p.cdnHeaders()
+	if h["Referer"] != p.Referer || h["Origin"] != "https://cloudnestra.com" {
+		t.Errorf("cdnHeaders() = %v, want origin derived from the referer", h)
+	}
+
+	p.Origin = "https://player.example.test"
+	if h := p.cdnHeaders(); h["Origin"] != p.Origin {
+		t.Errorf("cdnHeaders() = %v, want the configured origin", h)
+	}
+
+	if h := (ProviderConfig{}).cdnHeaders(); len(h) != 0 {
+		t.Errorf("cdnHeaders() without a referer = %v, want none", h)
+	}
+}
+
+func TestDurationJSON(t *testing.T) {
+	var d Duration
+	if err := json.Unmarshal([]byte(`"10s"`), &d); err != nil || d != Duration(10*time.Second) {
+		t.Errorf(`Unmarshal("10s") = %v, %v; want 10s`, time.Duration(d), err)
+	}
+	if b, err := json.Marshal(Duration(90 * time.Second)); err != nil || string(b) != `"1m30s"` {
+		t.Errorf("Marshal(90s) = %s, %v; want \"1m30s\"", b, err)
+	}
+	for _, bad := range []string{`10`, `"10"`, `"soon"`, `true`} {
+		var d Duration
+		if err := json.Unmarshal([]byte(bad), &d); err == nil {
+			t.Errorf("Unmarshal(%s) succeeded with %v", bad, time.Duration(d))
+		}
+	}
+}
+
+func TestLoadConfigTimeoutDefaults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"timeouts": {"playlist": "30s"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := DefaultConfig().Timeouts
+	want.Playlist = Duration(30 * time.Second)
+	if cfg.Timeouts != want {
+		t.Errorf("Timeouts = %+v, want %+v", cfg.Timeouts, want)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"timeouts": {"embed": 5}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(path); err == nil {
+		t.Error("LoadConfig accepted a timeout without a unit")
+	}
+}
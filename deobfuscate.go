@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+)
+
+// runDeobfuscate decodes a hidden-div payload read from a file or stdin, so
+// decode schemes can be tried without running the whole pipeline.
+func runDeobfuscate(args []string) error {
+	flags := flag.NewFlagSet("deobfuscate", flag.ExitOnError)
+	scheme := flags.String("scheme", "auto", "decode scheme: auto, "+strings.Join(schemeNames(), ", "))
+	flags.Parse(args)
+	if flags.NArg() > 1 {
+		return errors.New(msg(msgDeobfuscateUsage))
+	}
+
+	var in io.Reader = os.Stdin
+	if name := flags.Arg(0); name != "" && name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return fmt.Errorf("opening payload: %w", err)
+		}
+		defer f.Close()
+		in = f
+	}
+	data, err := io.ReadAll(in)
+	if err != nil {
+		return fmt.Errorf("reading payload: %w", err)
+	}
+	payload := strings.TrimSpace(string(data))
+
+	out, err := deobfuscateWith(*scheme, payload)
+	if err != nil {
+		return err
+	}
+	fmt.Println(out)
+	return nil
+}
+
+// deobfuscateWith decodes payload with the named scheme, or with whichever
+// scheme yields the most plausible stream URL when name is "auto".
+func deobfuscateWith(name, payload string) (string, error) {
+	if name == "auto" {
+		s, out, err := detectScheme(payload)
+		if err != nil {
+			return "", err
+		}
+		log.Printf("Decoded with scheme %s", s.Name)
+		return out, nil
+	}
+
+	s, ok := lookupScheme(name)
+	if !ok {
+		return "", fmt.Errorf("unknown scheme %q (known: auto, %s)", name, strings.Join(schemeNames(), ", "))
+	}
+	out, err := s.Decode(payload)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", s.Name, err)
+	}
+	return out, nil
+}
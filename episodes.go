@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Episode identifies one TV episode.
+type Episode struct {
+	Season  int
+	Episode int
+}
+
+func (e Episode) String() string {
+	return fmt.Sprintf("S%02dE%02d", e.Season, e.Episode)
+}
+
+func (e Episode) before(o Episode) bool {
+	return e.Season < o.Season || (e.Season == o.Season && e.Episode < o.Episode)
+}
+
+// EpisodeRange is an inclusive range of episodes, possibly spanning seasons.
+type EpisodeRange struct {
+	From, To Episode
+}
+
+var episodeRE = regexp.MustCompile(`(?i)^s(\d+)e(\d+)$`)
+
+// parseEpisode reads "S01E02", or a bare episode number in season.
+func parseEpisode(s string, season int) (Episode, error) {
+	s = strings.TrimSpace(s)
+	if m := episodeRE.FindStringSubmatch(s); m != nil {
+		se, _ := strconv.Atoi(m[1])
+		ep, _ := strconv.Atoi(m[2])
+		if se > 0 && ep > 0 {
+			return Episode{se, ep}, nil
+		}
+	}
+	if ep, err := strconv.Atoi(s); err == nil && ep > 0 {
+		if season <= 0 {
+			return Episode{}, fmt.Errorf("episode %q needs -season, or use the S01E02 form", s)
+		}
+		return Episode{season, ep}, nil
+	}
+	return Episode{}, fmt.Errorf("invalid episode %q: want 3 or S01E03", s)
+}
+
+// parseEpisodeRange reads an episode spec: a single episode ("3", "S01E03")
+// or a range ("1-5", "S01E01-S02E03"). Bare numbers are in season.
+func parseEpisodeRange(spec string, season int) (EpisodeRange, error) {
+	from, to, isRange := strings.Cut(spec, "-")
+	var r EpisodeRange
+	var err error
+	if r.From, err = parseEpisode(from, season); err != nil {
+		return r, err
+	}
+	if !isRange {
+		r.To = r.From
+		return r, nil
+	}
+	if r.To, err = parseEpisode(to, r.From.Season); err != nil {
+		return r, err
+	}
+	if r.To.before(r.From) {
+		return r, fmt.Errorf("episode range %q ends before it starts", spec)
+	}
+	return r, nil
+}
+
+// isNotFound reports whether err means the episode does not exist. A 404
+// from a later step means the episode exists but its stream is broken, and
+// is reported like any other failure.
+func isNotFound(err error) bool {
+	return errors.Is(err, errTitleNotFound)
+}
+
+// walkEpisodes resolves every episode in r in order, calling each with the
+// result. When a range spans seasons the episode count of a season is not
+// known up front, so a season ends at the first episode the provider does
+// not have and the walk moves on to episode 1 of the next one.
+func walkEpisodes(r EpisodeRange, resolve func(Episode) (*MasterPlaylist, error), each func(Episode, *MasterPlaylist, error)) {
+	ep := r.From
+	for !r.To.before(ep) {
+		master, err := resolve(ep)
+		if isNotFound(err) && ep.Season < r.To.Season && ep.Episode > 1 {
+			ep = Episode{ep.Season + 1, 1}
+			continue
+		}
+		each(ep, master, err)
+		if ep.Season < r.To.Season && ep.Episode == 1 && isNotFound(err) {
+			// The season has no episodes at all; skip to the next one.
+			ep = Episode{ep.Season + 1, 1}
+			continue
+		}
+		ep.Episode++
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestParseEpisodeRange(t *testing.T) {
+	tests := []struct {
+		spec   string
+		season int
+		want   EpisodeRange
+	}{
+		{"3", 1, EpisodeRange{Episode{1, 3}, Episode{1, 3}}},
+		{"1-5", 2, EpisodeRange{Episode{2, 1}, Episode{2, 5}}},
+		{"S01E03", 0, EpisodeRange{Episode{1, 3}, Episode{1, 3}}},
+		{"S01E01-S02E03", 0, EpisodeRange{Episode{1, 1}, Episode{2, 3}}},
+		{"s1e8-10", 0, EpisodeRange{Episode{1, 8}, Episode{1, 10}}},
+	}
+	for _, tt := range tests {
+		got, err := parseEpisodeRange(tt.spec, tt.season)
+		if err != nil || got != tt.want {
+			t.Errorf("parseEpisodeRange(%q, %d) = %v, %v; want %v", tt.spec, tt.season, got, err, tt.want)
+		}
+	}
+
+	for _, spec := range []string{"", "5-1", "S02E01-S01E05", "1-5", "S00E01", "x"} {
+		if _, err := parseEpisodeRange(spec, 0); err == nil {
+			t.Errorf("parseEpisodeRange(%q, 0) succeeded", spec)
+		}
+	}
+}
+
+func TestWalkEpisodesAcrossSeasons(t *testing.T) {
+	// Season 1 has two episodes; season 2 has plenty.
+	seasonLen := map[int]int{1: 2, 2: 10}
+	resolve := func(ep Episode) (*MasterPlaylist, error) {
+		if ep.Episode > seasonLen[ep.Season] {
+			return nil, errTitleNotFound
+		}
+		return &MasterPlaylist{}, nil
+	}
+
+	var got []Episode
+	r := EpisodeRange{Episode{1, 1}, Episode{2, 3}}
+	walkEpisodes(r, resolve, func(ep Episode, _ *MasterPlaylist, err error) {
+		if err != nil {
+			t.Errorf("%s: %v", ep, err)
+		}
+		got = append(got, ep)
+	})
+
+	want := []Episode{{1, 1}, {1, 2}, {2, 1}, {2, 2}, {2, 3}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("walked %v, want %v", got, want)
+	}
+}
+
+func TestWalkEpisodesReportsMissingInLastSeason(t *testing.T) {
+	resolve := func(ep Episode) (*MasterPlaylist, error) {
+		if ep.Episode == 2 {
+			return nil, errTitleNotFound
+		}
+		return &MasterPlaylist{}, nil
+	}
+
+	var failed []Episode
+	walkEpisodes(EpisodeRange{Episode{1, 1}, Episode{1, 3}}, resolve, func(ep Episode, _ *MasterPlaylist, err error) {
+		if err != nil {
+			failed = append(failed, ep)
+		}
+	})
+	if want := []Episode{{1, 2}}; !reflect.DeepEqual(failed, want) {
+		t.Errorf("failed episodes = %v, want %v", failed, want)
+	}
+}
+
+func TestWalkEpisodesReportsBrokenEpisodeMidSeason(t *testing.T) {
+	// Episode 2's RCP page is gone; the season still has episodes 3 and 4.
+	resolve := func(ep Episode) (*MasterPlaylist, error) {
+		switch {
+		case ep.Season == 1 && ep.Episode == 2:
+			return nil, &statusError{Code: http.StatusNotFound, What: "page"}
+		case ep.Episode > 4:
+			return nil, errTitleNotFound
+		}
+		return &MasterPlaylist{}, nil
+	}
+
+	var got, failed []Episode
+	walkEpisodes(EpisodeRange{Episode{1, 1}, Episode{2, 1}}, resolve, func(ep Episode, _ *MasterPlaylist, err error) {
+		got = append(got, ep)
+		if err != nil {
+			failed = append(failed, ep)
+		}
+	})
+	if want := []Episode{{1, 1}, {1, 2}, {1, 3}, {1, 4}, {2, 1}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("walked %v, want %v", got, want)
+	}
+	if want := []Episode{{1, 2}}; !reflect.DeepEqual(failed, want) {
+		t.Errorf("failed episodes = %v, want %v", failed, want)
+	}
+}
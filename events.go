@@ -0,0 +1,56 @@
+package main
+
+// EventKind says what happened to a pipeline step.
+type EventKind int
+
+const (
+	StepStarted EventKind = iota
+	StepSucceeded
+	StepFailed
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case StepStarted:
+		return "started"
+	case StepSucceeded:
+		return "succeeded"
+	case StepFailed:
+		return "failed"
+	}
+	return "unknown"
+}
+
+// Pipeline steps, in the order they run.
+const (
+	StepEmbed    = "embed"    // fetch the embed page and find the RCP URL
+	StepRCP      = "rcp"      // fetch the RCP page and find the ProRCP URL
+	StepDecode   = "decode"   // fetch the ProRCP page and decode the master URL
+	StepPlaylist = "playlist" // fetch and parse the master playlist
+)
+
+// Event reports progress through the resolution pipeline to OnEvent.
+type Event struct {
+	Kind     EventKind
+	Step     string
+	Artifact string // StepSucceeded: the URL the step produced
+	Err      error  // StepFailed: why
+}
+
+// step runs one pipeline step, reporting its start and outcome to OnEvent.
+func (o ResolveOptions) step(name string, run func() (string, error)) (string, error) {
+	o.emit(Event{Kind: StepStarted, Step: name})
+	out, err := run()
+	if err != nil {
+		o.emit(Event{Kind: StepFailed, Step: name, Err: err})
+		return "", err
+	}
+	o.emit(Event{Kind: StepSucceeded, Step: name, Artifact: out})
+	return out, nil
+}
+
+func (o ResolveOptions) emit(e Event) {
+	if o.OnEvent != nil {
+		o.OnEvent(e)
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// extractQuery is one ad-hoc extraction: a CSS selector, a regex, or both,
+// in which case the regex runs over what the selector matched.
+type extractQuery struct {
+	Selector string
+	Attr     string // print this attribute instead of the text
+	Regex    string
+}
+
+func runExtract(args []string) error {
+	flags := flag.NewFlagSet("extract", flag.ExitOnError)
+	pageURL := flags.String("url", "", "page to fetch")
+	referer := flags.String("referer", "", "Referer header to send")
+	file := flags.String("file", "", "read the page from a file instead of fetching it")
+	var q extractQuery
+	flags.StringVar(&q.Selector, "selector", "", "CSS selector to apply")
+	flags.StringVar(&q.Attr, "attr", "", "print this attribute of the selected elements instead of their text")
+	flags.StringVar(&q.Regex, "regex", "", "regular expression to apply; the first group is printed if it has one")
+	flags.Parse(args)
+	if (*pageURL == "") == (*file == "") {
+		return errors.New(msg(msgExtractUsage))
+	}
+
+	var page string
+	if *file != "" {
+		data, err := os.ReadFile(*file)
+		if err != nil {
+			return fmt.Errorf("reading page: %w", err)
+		}
+		page = string(data)
+	} else {
+		if _, err := loadConfig(); err != nil {
+			return err
+		}
+		headers := vidsrc.requestHeaders(false)
+		if *referer != "" {
+			headers["Referer"] = *referer
+		}
+		var err error
+		if page, err = fetchContent(*pageURL, headers, timeouts.DecodePage); err != nil {
+			return err
+		}
+	}
+
+	if q.Selector == "" && q.Regex == "" {
+		return extractREPL(page, os.Stdin, os.Stdout)
+	}
+	return q.run(page, os.Stdout)
+}
+
+// run applies q to page and prints one match per line.
+func (q extractQuery) run(page string, w io.Writer) error {
+	matches, err := q.apply(page)
+	if err != nil {
+		return err
+	}
+	for _, m := range matches {
+		fmt.Fprintln(w, m)
+	}
+	if len(matches) == 0 {
+		fmt.Fprintln(w, msg(msgNoMatches))
+	}
+	return nil
+}
+
+func (q extractQuery) apply(page string) ([]string, error) {
+	inputs := []string{page}
+	if q.Selector != "" {
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+		if err != nil {
+			return nil, fmt.Errorf("parsing page: %w", err)
+		}
+		sel := doc.Find(q.Selector)
+		inputs = nil
+		sel.Each(func(_ int, s *goquery.Selection) {
+			if q.Attr != "" {
+				if v, ok := s.Attr(q.Attr); ok {
+					inputs = append(inputs, v)
+				}
+				return
+			}
+			inputs = append(inputs, strings.TrimSpace(s.Text()))
+		})
+	}
+	if q.Regex == "" {
+		return inputs, nil
+	}
+
+	re, err := regexp.Compile(q.Regex)
+	if err != nil {
+		return nil, fmt.Errorf("compiling regex: %w", err)
+	}
+	var out []string
+	for _, in := range inputs {
+		for _, m := range re.FindAllStringSubmatch(in, -1) {
+			if len(m) > 1 {
+				out = append(out, m[1])
+			} else {
+				out = append(out, m[0])
+			}
+		}
+	}
+	return out, nil
+}
+
+// extractREPL reads queries from r against the already-fetched page, so
+// rules can be iterated on without refetching. Each line is one of
+//
+//	css <selector>
+//	attr <selector> <attribute>
+//	re <regex>
+func extractREPL(page string, r io.Reader, w io.Writer) error {
+	fmt.Fprintln(w, msg(msgExtractBanner, len(page)))
+	in := bufio.NewScanner(r)
+	for fmt.Fprint(w, "> "); in.Scan(); fmt.Fprint(w, "> ") {
+		cmd, arg, _ := strings.Cut(strings.TrimSpace(in.Text()), " ")
+		arg = strings.TrimSpace(arg)
+		var q extractQuery
+		switch cmd {
+		case "":
+			continue
+		case "css":
+			q.Selector = arg
+		case "attr":
+			i := strings.LastIndexByte(arg, ' ')
+			if i < 0 {
+				fmt.Fprintln(w, msg(msgExtractAttr))
+				continue
+			}
+			q.Selector, q.Attr = strings.TrimSpace(arg[:i]), arg[i+1:]
+		case "re":
+			q.Regex = arg
+		default:
+			fmt.Fprintln(w, msg(msgExtractUnknown, cmd))
+			continue
+		}
+		if err := q.run(page, w); err != nil {
+			fmt.Fprintln(w, msg(msgExtractError, err))
+		}
+	}
+	fmt.Fprintln(w)
+	return in.Err()
+}
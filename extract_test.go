@@ -0,0 +1,48 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const extractPage = `<html><body>
+<iframe id="player_iframe" src="//cloudnestra.com/rcp/abc"></iframe>
+<div style="display:none;" id="x1">payload-one</div>
+<script>var a = { src: '/prorcp/xyz' };</script>
+</body></html>`
+
+func TestExtractQuery(t *testing.T) {
+	tests := []struct {
+		q    extractQuery
+		want []string
+	}{
+		{extractQuery{Selector: "iframe#player_iframe", Attr: "src"}, []string{"//cloudnestra.com/rcp/abc"}},
+		{extractQuery{Selector: "div[style='display:none;']"}, []string{"payload-one"}},
+		{extractQuery{Regex: `src: '(/prorcp/[^']+)`}, []string{"/prorcp/xyz"}},
+		{extractQuery{Selector: "div", Regex: `payload-\w+`}, []string{"payload-one"}},
+		{extractQuery{Selector: "video"}, nil},
+	}
+	for _, tt := range tests {
+		got, err := tt.q.apply(extractPage)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%+v: got %q, %v; want %q", tt.q, got, err, tt.want)
+		}
+	}
+	if _, err := (extractQuery{Regex: "("}).apply(extractPage); err == nil {
+		t.Error("invalid regex accepted")
+	}
+}
+
+func TestExtractREPL(t *testing.T) {
+	var out strings.Builder
+	in := strings.NewReader("attr iframe src\nre /prorcp/\\w+\nnope\n")
+	if err := extractREPL(extractPage, in, &out); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"//cloudnestra.com/rcp/abc", "/prorcp/xyz", msg(msgExtractUnknown, "nope")} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("REPL output missing %q:\n%s", want, out.String())
+		}
+	}
+}
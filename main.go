@@ -2,23 +2,34 @@ package main
 
 import (
 	"encoding/base64"
+	"errors"
+	"flag"
 	"fmt"
+	"html"
 	"io"
 	"log"
 	"net/http"
 	"net/url"
 	"os"
+	"path"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/PuerkitoBio/goquery"
 )
 
-// shared HTTP client with timeout
-var client = &http.Client{
-	Timeout: 10 * time.Second,
-}
+// shared HTTP client, rebuilt from the config file in main
+var client = DefaultConfig().HTTP.NewClient()
+
+// per-step request timeouts, replaced from the config file in main
+var timeouts = DefaultConfig().Timeouts
+
+// vidsrc provider hosts and headers, replaced from the config file in main
+var vidsrc = DefaultConfig().Providers["vidsrc"]
 
 // MediaType is the type of content (movie or tv).
 type MediaType string
@@ -34,6 +45,16 @@ type ResolveOptions struct {
 	Type    MediaType
 	Season  int
 	Episode int
+
+	// Server picks one entry, counting from 1, of the servers the embed page
+	// lists. Zero tries each in turn until one yields a working stream.
+	Server int
+
+	// OnEvent, if set, is called as each pipeline step starts, succeeds or
+	// fails, so frontends can show progress without parsing the log. Steps
+	// run again, and are reported again, when an expired playlist forces a
+	// second pass or the next server is tried.
+	OnEvent func(Event)
 }
 
 // StreamVariant represents one HLS variant (quality level).
@@ -41,118 +62,226 @@ type StreamVariant struct {
 	Resolution string
 	Bandwidth  string
 	URL        string
+	Codecs     string // CODECS attribute, e.g. "avc1.640028,mp4a.40.2"
+	VideoRange string // VIDEO-RANGE attribute: SDR, PQ or HLG
+	FrameRate  string // FRAME-RATE attribute, e.g. "23.976"
+	Audio      string // GROUP-ID of the variant's audio renditions, if any
+	Subtitles  string // GROUP-ID of the variant's subtitle renditions, if any
+}
+
+// Artifacts are the intermediate results of a resolution, for frontends
+// and bug reports that need to see where the pipeline diverged. The
+// per-server fields describe the last server tried.
+type Artifacts struct {
+	EmbedURL  string
+	Server    string // name of the server on the embed page
+	RCPURL    string
+	ProRCPURL string
+	Decoded   string // hidden-div payload as decoded, before validation
+	MasterURL string
+	Master    *MasterPlaylist // nil unless resolution succeeded
 }
 
 // ResolveVariants runs the full resolution pipeline and returns the final HLS master URL.
 func (o ResolveOptions) ResolveVariants() (string, error) {
 	log.Println("Starting stream resolution...")
 
-	// Step 1: Build and fetch the initial embed page
-	embedURL, err := o.buildEmbedURL()
+	var a Artifacts
+	err := o.eachServer(&a, func(s server) error {
+		return o.resolveServer(s, &a)
+	})
 	if err != nil {
 		return "", err
 	}
-	log.Printf("Built embed URL: %s", embedURL)
+	return a.MasterURL, nil
+}
 
-	embedHTML, err := fetchContent(embedURL, "")
-	if err != nil {
-		return "", err
-	}
+// server is one entry of the embed page's server list.
+type server struct {
+	Name   string
+	RCPURL string // protocol-relative, as found on the embed page
+}
 
-	// Step 2: Extract the RCP URL from the iframe
-	rcpURL, err := extractRCPURL(embedHTML)
-	if err != nil {
-		return "", err
-	}
-	log.Printf("Found RCP URL: %s", rcpURL)
+// eachServer fetches the embed page and calls try with the server picked by
+// o.Server, or with each listed server in turn until one succeeds.
+func (o ResolveOptions) eachServer(a *Artifacts, try func(server) error) error {
+	var servers []server
+	_, err := o.step(StepEmbed, func() (string, error) {
+		embedURL, err := o.buildEmbedURL()
+		if err != nil {
+			return "", err
+		}
+		log.Printf("Built embed URL: %s", embedURL)
+		a.EmbedURL = embedURL
 
-	// Step 3: Fetch the RCP page content
-	rcpHTML, err := fetchContent("https:"+rcpURL, "")
+		embedHTML, err := fetchContent(embedURL, vidsrc.requestHeaders(false), timeouts.Embed)
+		if se := (*statusError)(nil); errors.As(err, &se) && se.Code == http.StatusNotFound {
+			return "", fmt.Errorf("%w: %w", errTitleNotFound, err)
+		}
+		if err != nil {
+			return "", err
+		}
+		if servers, err = extractServers(embedHTML); err != nil {
+			return "", err
+		}
+		if o.Server != 0 {
+			if o.Server < 0 || o.Server > len(servers) {
+				return "", fmt.Errorf("server %d not found; the embed page lists %d", o.Server, len(servers))
+			}
+			servers = servers[o.Server-1 : o.Server]
+		}
+		return servers[0].RCPURL, nil
+	})
 	if err != nil {
-		return "", err
+		return err
 	}
 
-	// Step 4: Extract the ProRCP URL from the RCP page
-	proRCPURL, err := extractProRCPURL(rcpHTML)
-	if err != nil {
-		return "", err
+	var errs []error
+	for i, s := range servers {
+		log.Printf("Trying server %q: %s", s.Name, s.RCPURL)
+		*a = Artifacts{EmbedURL: a.EmbedURL, Server: s.Name, RCPURL: "https:" + s.RCPURL}
+		err := try(s)
+		if err == nil {
+			return nil
+		}
+		if len(servers) == 1 {
+			return err
+		}
+		if i+1 < len(servers) {
+			log.Printf("Server %q failed, trying the next one: %v", s.Name, err)
+		}
+		errs = append(errs, fmt.Errorf("server %q: %w", s.Name, err))
 	}
-	log.Printf("Found ProRCP URL: %s", proRCPURL)
+	return errors.Join(errs...)
+}
 
-	// Step 5: Fetch the ProRCP page with the correct Referer
-	proRCPHTML, err := fetchContent("https://cloudnestra.com"+proRCPURL, "https://cloudnestra.com")
+// resolveServer runs the RCP and decode steps for one server, recording
+// their results in a.
+func (o ResolveOptions) resolveServer(s server, a *Artifacts) error {
+	// Step 2: Fetch the RCP page and extract the ProRCP URL from it
+	proRCPURL, err := o.step(StepRCP, func() (string, error) {
+		rcpHTML, err := fetchContent(a.RCPURL, vidsrc.requestHeaders(false), timeouts.DecodePage)
+		if err != nil {
+			return "", err
+		}
+		path, err := extractProRCPURL(rcpHTML)
+		if err != nil {
+			return "", err
+		}
+		return vidsrc.PlayerBase + path, nil
+	})
 	if err != nil {
-		return "", err
+		return err
 	}
+	log.Printf("Found ProRCP URL: %s", proRCPURL)
+	a.ProRCPURL = proRCPURL
 
-	// Step 6: Decode the stream URL from the ProRCP page
-	hlsURL, err := decodeStreamURL(proRCPHTML)
+	// Step 3: Fetch the ProRCP page with the player host's Referer and decode the stream URL
+	hlsURL, err := o.step(StepDecode, func() (string, error) {
+		proRCPHTML, err := fetchContent(proRCPURL, vidsrc.requestHeaders(true), timeouts.DecodePage)
+		if err != nil {
+			return "", err
+		}
+		if a.Decoded, err = decodeHiddenPayload(proRCPHTML); err != nil {
+			return "", err
+		}
+		return validateStreamURL(a.Decoded, vidsrc.Placeholders)
+	})
 	if err != nil {
-		return "", err
+		return err
 	}
 	log.Printf("Decoded HLS URL: %s", hlsURL)
+	a.MasterURL = hlsURL
 
-	return hlsURL, nil
+	return nil
 }
 
 // ResolveStreams fetches the master playlist and extracts all variant streams.
 func (o ResolveOptions) ResolveStreams() ([]StreamVariant, error) {
-	masterURL, err := o.ResolveVariants()
+	master, err := o.ResolveMaster()
 	if err != nil {
 		return nil, err
 	}
-	log.Printf("Fetching master playlist from: %s", masterURL)
+	return master.Variants, nil
+}
 
-	resp, err := client.Get(masterURL)
+// ResolveMaster fetches and parses the master playlist, including its
+// alternative audio and subtitle renditions. A server whose stream cannot be
+// decoded or whose playlist is dead is skipped for the next one.
+func (o ResolveOptions) ResolveMaster() (*MasterPlaylist, error) {
+	a, err := o.ResolveArtifacts()
 	if err != nil {
-		return nil, fmt.Errorf("fetching master playlist %q: %w", masterURL, err)
+		return nil, err
 	}
-	defer resp.Body.Close()
+	return a.Master, nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status %d for master playlist %q", resp.StatusCode, masterURL)
-	}
+// ResolveArtifacts is ResolveMaster returning the intermediate URLs and the
+// decoded payload along with the playlist. On failure the artifacts gathered
+// so far are returned with the error.
+func (o ResolveOptions) ResolveArtifacts() (*Artifacts, error) {
+	log.Println("Starting stream resolution...")
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("reading master playlist %q: %w", masterURL, err)
-	}
-
-	lines := strings.Split(string(body), "\n")
-	var variants []StreamVariant
-
-	for i, line := range lines {
-		line = strings.TrimSpace(line)
-		if strings.HasPrefix(line, "#EXT-X-STREAM-INF") {
-			attrs := parseAttributes(line)
-			resolution := attrs["RESOLUTION"]
-			bandwidth := attrs["BANDWIDTH"]
-			if i+1 < len(lines) {
-				urlLine := strings.TrimSpace(lines[i+1])
-				if urlLine != "" && !strings.HasPrefix(urlLine, "#") {
-					abs := resolveRelativeURL(masterURL, urlLine)
-					variant := StreamVariant{
-						Resolution: resolution,
-						Bandwidth:  bandwidth,
-						URL:        abs,
-					}
-					variants = append(variants, variant)
-					log.Printf("Found variant: Resolution=%s, Bandwidth=%s", resolution, bandwidth)
-				}
-			}
+	a := &Artifacts{}
+	err := o.eachServer(a, func(s server) error {
+		if err := o.resolveServer(s, a); err != nil {
+			return err
+		}
+		log.Printf("Fetching master playlist from: %s", a.MasterURL)
+
+		o.emit(Event{Kind: StepStarted, Step: StepPlaylist})
+		master, err := o.fetchMaster(s, a)
+		if err != nil {
+			return err // reported by fetchMaster
 		}
+		o.emit(Event{Kind: StepSucceeded, Step: StepPlaylist, Artifact: master.URL})
+		a.Master = master
+		return nil
+	})
+	return a, err
+}
+
+// fetchMaster fetches and filters the master playlist at a.MasterURL,
+// resolving s again once if it has already expired. It reports the outcome
+// of the playlist step: the expired attempt as a failed playlist step before
+// the earlier steps run again, and a failure of the second pass by the step
+// that failed.
+func (o ResolveOptions) fetchMaster(s server, a *Artifacts) (*MasterPlaylist, error) {
+	failed := func(err error) (*MasterPlaylist, error) {
+		o.emit(Event{Kind: StepFailed, Step: StepPlaylist, Err: err})
+		return nil, err
 	}
 
-	if len(variants) == 0 {
-		return nil, fmt.Errorf("no stream variants found in master playlist %q", masterURL)
+	body, err := fetchPlaylist(a.MasterURL)
+	// Decode pages sometimes hand out URLs that have already expired; a fresh
+	// pass through the pipeline usually yields a working one.
+	var se *statusError
+	if errors.As(err, &se) && (se.Code == http.StatusNotFound || se.Code == http.StatusForbidden) {
+		log.Printf("Master playlist returned %d, resolving again...", se.Code)
+		o.emit(Event{Kind: StepFailed, Step: StepPlaylist, Err: err})
+		if err := o.resolveServer(s, a); err != nil {
+			return nil, err
+		}
+		o.emit(Event{Kind: StepStarted, Step: StepPlaylist})
+		body, err = fetchPlaylist(a.MasterURL)
+	}
+	if err != nil {
+		return failed(err)
 	}
 
-	log.Printf("Found %d stream variants.", len(variants))
-	return variants, nil
+	master, err := parseMasterPlaylist(a.MasterURL, body)
+	if err != nil {
+		return failed(err)
+	}
+	if master.Variants, err = cdnFilter.filterVariants(master.Variants); err != nil {
+		return failed(err)
+	}
+	master.Renditions = cdnFilter.filterRenditions(master.Renditions)
+	return master, nil
 }
 
 func (o ResolveOptions) buildEmbedURL() (string, error) {
-	const vidsrcBase = "https://vidsrc-embed.ru" // Updated base URL
+	vidsrcBase := vidsrc.EmbedBase
 
 	switch o.Type {
 	case Movie:
@@ -176,14 +305,28 @@ func (o ResolveOptions) buildEmbedURL() (string, error) {
 	}
 }
 
-func fetchContent(url, referer string) (string, error) {
+// statusError reports a response with an unexpected HTTP status.
+type statusError struct {
+	Code int
+	What string // "page" or "playlist"
+	URL  string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unexpected status %d for %s %q", e.Code, e.What, e.URL)
+}
+
+func fetchContent(url string, headers map[string]string, timeout Duration) (string, error) {
 	log.Printf("Fetching page: %s", url)
-	req, err := http.NewRequest("GET", url, nil)
+	ctx, cancel := stepContext(timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return "", fmt.Errorf("creating request for %q: %w", url, err)
 	}
-	if referer != "" {
-		req.Header.Set("Referer", referer)
+	for k, v := range headers {
+		req.Header.Set(k, v)
 	}
 
 	resp, err := client.Do(req)
@@ -193,7 +336,7 @@ func fetchContent(url, referer string) (string, error) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("unexpected status code %d for page %q", resp.StatusCode, url)
+		return "", &statusError{Code: resp.StatusCode, What: "page", URL: url}
 	}
 
 	body, err := io.ReadAll(resp.Body)
@@ -203,19 +346,50 @@ func fetchContent(url, referer string) (string, error) {
 	return string(body), nil
 }
 
-func extractRCPURL(embedHTML string) (string, error) {
-	log.Println("Parsing embed HTML to find iframe src for RCP URL...")
+// extractServers returns the servers listed on the embed page, starting
+// with the one its player iframe loads by default.
+func extractServers(embedHTML string) ([]server, error) {
+	log.Println("Parsing embed HTML to find the RCP URLs of its servers...")
 	doc, err := goquery.NewDocumentFromReader(strings.NewReader(embedHTML))
 	if err != nil {
-		return "", fmt.Errorf("parsing embed HTML: %w", err)
+		return nil, fmt.Errorf("parsing embed HTML: %w", err)
 	}
 
-	src, exists := doc.Find("iframe#player_iframe").Attr("src")
-	if !exists || src == "" {
-		return "", fmt.Errorf("no iframe src found for RCP URL")
+	// Server hashes are RCP paths on the same host as the default iframe.
+	prefix := strings.TrimPrefix(strings.TrimPrefix(vidsrc.PlayerBase, "https:"), "http:") + "/rcp/"
+	var servers []server
+	src, _ := doc.Find("iframe#player_iframe").Attr("src")
+	if src != "" {
+		log.Printf("Found iframe source for RCP: %s", src)
+		if i := strings.Index(src, "/rcp/"); i >= 0 {
+			prefix = src[:i+len("/rcp/")]
+		}
+		servers = append(servers, server{Name: "default", RCPURL: src})
+	}
+
+	doc.Find(".server[data-hash]").Each(func(_ int, sel *goquery.Selection) {
+		hash := strings.TrimSpace(sel.AttrOr("data-hash", ""))
+		if hash == "" {
+			return
+		}
+		s := server{Name: strings.TrimSpace(sel.Text()), RCPURL: prefix + hash}
+		if s.Name == "" {
+			s.Name = fmt.Sprintf("server %d", len(servers)+1)
+		}
+		for i := range servers {
+			if servers[i].RCPURL == s.RCPURL {
+				// Usually the default iframe; give it the listed name.
+				servers[i].Name = s.Name
+				return
+			}
+		}
+		servers = append(servers, s)
+	})
+
+	if len(servers) == 0 {
+		return nil, fmt.Errorf("no iframe src or server list found for RCP URL")
 	}
-	log.Printf("Found iframe source for RCP: %s", src)
-	return src, nil
+	return servers, nil
 }
 
 func extractProRCPURL(rcpHTML string) (string, error) {
@@ -229,7 +403,9 @@ func extractProRCPURL(rcpHTML string) (string, error) {
 	return match[1], nil
 }
 
-func decodeStreamURL(proRCPHTML string) (string, error) {
+// decodeHiddenPayload decodes the hidden divs of a ProRCP page and returns
+// the output that looks most like a stream URL.
+func decodeHiddenPayload(proRCPHTML string) (string, error) {
 	log.Println("Decoding stream URL from ProRCP HTML...")
 
 	doc, err := goquery.NewDocumentFromReader(strings.NewReader(proRCPHTML))
@@ -242,11 +418,11 @@ func decodeStreamURL(proRCPHTML string) (string, error) {
 	if scriptSel.Length() > 0 {
 		src, exists := scriptSel.First().Attr("src")
 		if exists {
-			fullURL := "https://cloudnestra.com" + src
+			fullURL := vidsrc.PlayerBase + src
 			log.Printf("Found JS file URL: %s", fullURL)
 
 			// Fetch content
-			jsContent, err := fetchContent(fullURL, "https://cloudnestra.com")
+			jsContent, err := fetchContent(fullURL, vidsrc.requestHeaders(true), timeouts.DecodePage)
 			if err != nil {
 				log.Printf("Failed to fetch JS content: %v", err)
 			} else {
@@ -267,37 +443,134 @@ func decodeStreamURL(proRCPHTML string) (string, error) {
 		log.Println("No script found with src containing /sV05kUlNvOdOxvtC/")
 	}
 
-	// 2. Extract Hidden Div Content and ID
-	var divContent string
-	divSel := doc.Find("div[style='display:none;']")
-	if divSel.Length() > 0 {
-		divContent = strings.TrimSpace(divSel.First().Text())
-		log.Printf("Hidden Div found, length: %d", len(divContent))
-	} else {
-		log.Println("No hidden div found with style='display:none;'")
+	// 2. Collect every hidden div; decorative ones are common, so the
+	// payload is not necessarily the first
+	candidates := hiddenDivs(doc)
+	if len(candidates) == 0 {
+		log.Println("No hidden div found with display:none")
 		return "", fmt.Errorf("no hidden div found")
 	}
+	log.Printf("Found %d hidden div candidates", len(candidates))
 
-	// 3. Decode the content directly
-	fmt.Println("DivContent: ")
-	fmt.Println(divContent)
-
-	if divContent != "" {
-		decodedURL, err := Deobfuscate(divContent)
+	// 3. Decode each candidate and keep the most plausible stream URL
+	best, bestScore := "", 0
+	var errs []string
+	for i, c := range candidates {
+		decoded, err := decodePayload(c)
 		if err != nil {
-			return "", fmt.Errorf("deobfuscating content: %w", err)
+			errs = append(errs, fmt.Sprintf("div %d: %v", i+1, err))
+			continue
+		}
+		score := streamURLScore(decoded)
+		log.Printf("Hidden div %d (length %d) decoded, score %d", i+1, len(c), score)
+		if score > bestScore {
+			best, bestScore = decoded, score
+		}
+	}
+	if bestScore == 0 {
+		if len(errs) > 0 {
+			return "", fmt.Errorf("deobfuscating content: %s", strings.Join(errs, "; "))
+		}
+		return "", fmt.Errorf("deobfuscating content: %w", errNonURL)
+	}
+	return best, nil
+}
+
+// errTitleNotFound is returned when the embed page is missing, meaning the
+// provider does not have the title or episode; a 404 from a later step does
+// not mean that.
+var errTitleNotFound = errors.New("title not found")
+
+// errNonURL is returned when a payload decodes to something that is not a
+// usable stream URL, which usually means the obfuscation changed.
+var errNonURL = errors.New("decode produced non-URL output")
+
+var placeholderRE = regexp.MustCompile(`\{(v\d+)\}`)
+
+// validateStreamURL checks decoded output before it reaches the playlist
+// fetcher. The output may list alternatives separated by " or ", whose hosts
+// can contain {v1}-style placeholders; the first alternative that is an
+// http(s) URL to a playlist or media file, once placeholders are filled in
+// from placeholders, is returned.
+func validateStreamURL(decoded string, placeholders map[string]string) (string, error) {
+	var unresolved []string
+	for _, alt := range strings.Split(decoded, " or ") {
+		alt = placeholderRE.ReplaceAllStringFunc(strings.TrimSpace(alt), func(m string) string {
+			if v, ok := placeholders[m[1:len(m)-1]]; ok {
+				return v
+			}
+			return m
+		})
+		if m := placeholderRE.FindString(alt); m != "" {
+			unresolved = append(unresolved, m)
+			continue
+		}
+
+		u, err := url.Parse(alt)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			continue
+		}
+		switch strings.ToLower(path.Ext(u.Path)) {
+		case ".m3u8", ".m3u", ".mp4", ".mkv", ".ts":
+			return alt, nil
 		}
-		return decodedURL, nil
 	}
 
-	return "", fmt.Errorf("failed to extract necessary components for decoding")
+	if len(unresolved) > 0 {
+		return "", fmt.Errorf("%w: host placeholder %s has no value; set it under providers.vidsrc.placeholders", errNonURL, unresolved[0])
+	}
+	return "", fmt.Errorf("%w: %q", errNonURL, truncate(decoded, 80))
+}
+
+// truncate shortens s to at most n bytes for error messages.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
+
+// hiddenDivs returns the non-empty text of every div styled display:none,
+// in page order.
+func hiddenDivs(doc *goquery.Document) []string {
+	var out []string
+	doc.Find("div[style]").Each(func(_ int, s *goquery.Selection) {
+		style, _ := s.Attr("style")
+		style = strings.ToLower(strings.ReplaceAll(style, " ", ""))
+		if !strings.Contains(style, "display:none") {
+			return
+		}
+		if text := strings.TrimSpace(s.Text()); text != "" {
+			out = append(out, text)
+		}
+	})
+	return out
+}
+
+// streamURLScore rates how much decoded looks like a stream URL: 0 for
+// anything that is not http(s), more for playlist-looking paths.
+func streamURLScore(decoded string) int {
+	if !strings.HasPrefix(decoded, "http://") && !strings.HasPrefix(decoded, "https://") {
+		return 0
+	}
+	score := 1
+	if strings.Contains(decoded, ".m3u8") {
+		score += 2
+	}
+	if strings.Contains(decoded, "/pl/") || strings.Contains(decoded, "master") {
+		score++
+	}
+	return score
 }
 
 // Deobfuscate replicates the logic of the JS function:
 // 1. Reverse String -> 2. Take every 2nd char -> 3. Base64 Decode
 func Deobfuscate(obfCode string) (string, error) {
+	// Entities survive in the div when the page escapes it twice, and '&' and
+	// ';' would otherwise land in the base64 text.
+	obfCode = html.UnescapeString(obfCode)
+
 	// Convert to rune slice to safely handle characters
-	// fmt.Println(obfCode)
 	runes := []rune(obfCode)
 	n := len(runes)
 
@@ -308,17 +581,19 @@ func Deobfuscate(obfCode string) (string, error) {
 
 	// Step 2: Extract every 2nd character
 	// The JS loop was: i starts at 0, increments by 2
-	var filtered []rune
-	for i := 0; i < n; i += 2 {
-		filtered = append(filtered, runes[i])
-	}
-
-	filteredStr := string(filtered)
+	filteredStr := stride(runes, 0)
 
 	// Step 3: Base64 Decode
-	// We use RawStdEncoding to be permissive, or StdEncoding if padding is standard.
-	// Usually, standard StdEncoding is fine.
-	decodedBytes, err := base64.StdEncoding.DecodeString(filteredStr)
+	decodedBytes, err := decodeBase64(filteredStr)
+	if (err != nil || !isText(decodedBytes)) && n > 1 {
+		// An even-length blob carries the payload on the odd positions once
+		// reversed, e.g. when a filler character was appended. The even
+		// positions are then all filler, which may still decode, but not to
+		// text.
+		if alt, altErr := decodeBase64(stride(runes, 1)); altErr == nil && isText(alt) {
+			decodedBytes, err = alt, nil
+		}
+	}
 	if err != nil {
 		return "", fmt.Errorf("decoding Base64: %w", err)
 	}
@@ -326,16 +601,87 @@ func Deobfuscate(obfCode string) (string, error) {
 	return string(decodedBytes), nil
 }
 
+// isText reports whether b is UTF-8 without control characters other than
+// whitespace, as any decoded payload is.
+func isText(b []byte) bool {
+	if !utf8.Valid(b) {
+		return false
+	}
+	for _, r := range string(b) {
+		if unicode.IsControl(r) && !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// stride returns every second rune of runes, starting at start.
+func stride(runes []rune, start int) string {
+	var filtered []rune
+	for i := start; i < len(runes); i += 2 {
+		filtered = append(filtered, runes[i])
+	}
+	return string(filtered)
+}
+
+// decodeBase64 decodes standard or URL-safe base64, with or without padding,
+// ignoring whitespace. The provider has switched between these before, so
+// the alphabet is picked from the input rather than fixed.
+func decodeBase64(s string) ([]byte, error) {
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, s)
+	s = strings.TrimRight(s, "=")
+
+	enc := base64.RawStdEncoding
+	if strings.ContainsAny(s, "-_") {
+		enc = base64.RawURLEncoding
+	}
+	return enc.DecodeString(s)
+}
+
+// parseAttributes reads the attribute list of an m3u8 tag line such as
+// #EXT-X-STREAM-INF:BANDWIDTH=5000000,CODECS="avc1.640028,mp4a.40.2".
+// Quoted values may contain commas; malformed pairs are skipped.
 func parseAttributes(line string) map[string]string {
 	attrs := map[string]string{}
-	parts := strings.Split(line, ",")
-	for _, part := range parts {
-		if strings.Contains(part, "=") {
-			kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
-			key := kv[0]
-			val := strings.Trim(kv[1], "\"")
+	// Drop the tag name so the first attribute isn't read as "#EXT-X-STREAM-INF:BANDWIDTH".
+	if _, list, ok := strings.Cut(line, ":"); ok {
+		line = list
+	}
+
+	for line != "" {
+		key, rest, ok := strings.Cut(line, "=")
+		if !ok {
+			break
+		}
+		// A comma in the key means the previous pair had no "=".
+		if i := strings.LastIndexByte(key, ','); i >= 0 {
+			key = key[i+1:]
+		}
+		key = strings.TrimSpace(key)
+
+		var val string
+		rest = strings.TrimLeft(rest, " ")
+		if strings.HasPrefix(rest, "\"") {
+			quoted, after, closed := strings.Cut(rest[1:], "\"")
+			val = quoted
+			if !closed {
+				after = ""
+			}
+			_, rest, _ = strings.Cut(after, ",")
+		} else {
+			val, rest, _ = strings.Cut(rest, ",")
+			val = strings.TrimSpace(val)
+		}
+
+		if key != "" {
 			attrs[key] = val
 		}
+		line = rest
 	}
 	return attrs
 }
@@ -352,25 +698,299 @@ func resolveRelativeURL(baseStr, refStr string) string {
 	return base.ResolveReference(ref).String()
 }
 
+func usage() {
+	fmt.Fprintf(os.Stderr, "%s\n%s\n", msg(msgUsage), msg(msgFlags))
+	flag.PrintDefaults()
+}
+
+// mediaFlags are the flags that pick what to resolve, shared by the main
+// command and the subcommands that resolve a stream.
+type mediaFlags struct {
+	typ     *string
+	season  *int
+	episode *int
+	server  *int
+}
+
+func addMediaFlags(fs *flag.FlagSet) mediaFlags {
+	return mediaFlags{
+		typ:     fs.String("type", string(Movie), "media type: movie or tv"),
+		season:  fs.Int("season", 0, "season number (tv only)"),
+		episode: fs.Int("episode", 0, "episode number (tv only)"),
+		server:  fs.Int("server", 0, "use only the Nth server of the embed page instead of trying each in turn"),
+	}
+}
+
+func (m mediaFlags) options(imdbID string) ResolveOptions {
+	return ResolveOptions{
+		IMDBID:  imdbID,
+		Type:    MediaType(*m.typ),
+		Season:  *m.season,
+		Episode: *m.episode,
+		Server:  *m.server,
+	}
+}
+
+// applyConfig replaces the shared client, cache and settings with the ones
+// from cfg.
+func applyConfig(cfg Config) error {
+	if cfg.Language != "" {
+		if l, ok := supportedLanguage(cfg.Language); ok {
+			language = l
+		} else {
+			log.Printf("No translation for language %q, using %s", cfg.Language, language)
+		}
+	}
+	client = cfg.HTTP.NewClient()
+	timeouts = cfg.Timeouts
+	vidsrc = cfg.Providers["vidsrc"]
+	cdnFilter = cfg.CDN
+	statusSharing = cfg.Status
+
+	c, err := cfg.Cache.Open()
+	if err != nil {
+		return err
+	}
+	cache = c
+	cacheTTL = time.Duration(cfg.Cache.TTL)
+	return nil
+}
+
+// loadConfig reads the config file and applies it, for subcommands.
+func loadConfig() (Config, error) {
+	cfg, err := LoadConfig(defaultConfigPath())
+	if err != nil {
+		return cfg, err
+	}
+	return cfg, applyConfig(cfg)
+}
+
+// printVariants prints the variants of master, flagging suspicious ones,
+// and with durations also the length of each variant.
+func printVariants(master *MasterPlaylist, durations bool) {
+	for _, s := range master.Variants {
+		if s.hdr() {
+			fmt.Println(msg(msgVariantHDR, s.label(), s.Bandwidth, s.dynamicRange(), s.URL))
+		} else {
+			fmt.Println(msg(msgVariant, s.label(), s.Bandwidth, s.URL))
+		}
+		if s.lowBitrate() {
+			bw, _ := strconv.Atoi(s.Bandwidth)
+			log.Print(msg(msgLowBitrate, s.Resolution, bw/1000))
+		}
+	}
+
+	if !durations {
+		return
+	}
+	for _, v := range master.Variants {
+		sum, err := v.Summary()
+		if err != nil {
+			log.Print(msg(msgSummaryFailed, v.Resolution, err))
+			continue
+		}
+		fmt.Println(msg(msgVariantSummary, v.Resolution, formatTimestamp(sum.Duration), sum.Segments))
+		if sum.Duration < shortVariantDuration {
+			log.Print(msg(msgShortVariant, v.Resolution, formatTimestamp(sum.Duration)))
+		}
+	}
+}
+
+// resolveRange resolves and prints every episode in r with the other options
+// of base, reporting whether all of them succeeded. narrow filters each
+// episode's variants as -no-hdr, -fps and -profile ask.
+func resolveRange(base ResolveOptions, r EpisodeRange, narrow func(*MasterPlaylist), durations bool) bool {
+	ok := true
+	resolve := func(ep Episode) (*MasterPlaylist, error) {
+		opts := base
+		opts.Type, opts.Season, opts.Episode = TV, ep.Season, ep.Episode
+		return opts.resolveRecorded(lastFailurePath())
+	}
+	walkEpisodes(r, resolve, func(ep Episode, master *MasterPlaylist, err error) {
+		fmt.Println(msg(msgEpisode, ep))
+		if err != nil {
+			log.Print(msg(msgEpisodeFailed, ep, err))
+			ok = false
+			return
+		}
+		narrow(master)
+		printVariants(master, durations)
+	})
+	return ok
+}
+
 func main() {
-	// Example Movie: Iron Man 3 (2013)
-	opts := ResolveOptions{
-		// IMDBID:  "tt1300854", // IMDb ID for the title
-		// IMDBID: "tt30144838",
-		IMDBID: "tt0137523",
-		// IMDBID: "tt0099685",
-		Type:    Movie,       // Movie or TV
-		Season:  0,           // only needed for TV
-		Episode: 0,           // only needed for TV
-	}
-
-	streams, err := opts.ResolveStreams()
+	if len(os.Args) > 1 {
+		subcommands := map[string]struct {
+			run    func([]string) error
+			failed string // message key for the fatal error
+		}{
+			"backup":      {runBackup, msgBackupFailed},
+			"screenshot":  {runScreenshot, msgScreenshotFailed},
+			"probe":       {runProbe, msgProbeFailed},
+			"deobfuscate": {runDeobfuscate, msgDeobfuscateFailed},
+			"extract":     {runExtract, msgExtractFailed},
+			"report":      {runReport, msgReportFailed},
+			"status":      {runStatus, msgStatusFailed},
+		}
+		if sub, ok := subcommands[os.Args[1]]; ok {
+			if err := sub.run(os.Args[2:]); err != nil {
+				log.Fatal(msg(sub.failed, err))
+			}
+			return
+		}
+	}
+
+	var (
+		media       = addMediaFlags(flag.CommandLine)
+		audioDesc   = flag.Bool("audio-desc", false, "list audio-description tracks for visually impaired viewers")
+		episodes    = flag.String("e", "", "tv episode or range: 3, 1-5 (in -season) or S01E01-S02E03")
+		durations   = flag.Bool("durations", false, "fetch each variant's media playlist and report its duration and segment count")
+		runtimeFlag = flag.String("runtime", "", "expected runtime (minutes or e.g. 2h19m); warn when the stream is much shorter or longer")
+		play        = flag.Bool("play", false, "open the highest-bandwidth variant in mpv, IINA or VLC")
+		subs        = flag.String("subs", "", "list subtitle tracks matching lang[:kind], kind being regular, sdh or forced")
+		profileName = flag.String("profile", "", "apply a quality profile from the config to variant, audio and subtitle choices")
+		noHDR       = flag.Bool("no-hdr", false, "skip HDR10, HLG and Dolby Vision variants when SDR ones are available")
+		fps         = flag.String("fps", "", "prefer variants at these frame rates, e.g. 50,60, or avoid one with !24")
+		channels    = flag.String("audio-channels", "", "prefer the audio track closest to this layout: 2, 5.1, stereo or surround")
+	)
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() != 1 {
+		usage()
+		os.Exit(2)
+	}
+	subsPref, err := parseSubtitlePref(*subs)
+	if err != nil {
+		log.Fatal(msg(msgInvalidSubs, err))
+	}
+	fpsPref, err := parseFrameRatePref(*fps)
+	if err != nil {
+		log.Fatal(msg(msgInvalidFPS, err))
+	}
+	audioChannels, err := parseChannels(*channels)
+	if err != nil {
+		log.Fatal(msg(msgInvalidChannels, err))
+	}
+	var expected time.Duration
+	if *runtimeFlag != "" {
+		if expected, err = parseRuntime(*runtimeFlag); err != nil {
+			log.Fatal(msg(msgInvalidRuntime, err))
+		}
+	}
+
+	cfg, err := LoadConfig(defaultConfigPath())
+	if err != nil {
+		log.Fatal(msg(msgLoadConfig, err))
+	}
+	if err := applyConfig(cfg); err != nil {
+		log.Fatal(msg(msgOpenCache, err))
+	}
+	var profile *Profile
+	if *profileName != "" {
+		if profile, err = lookupProfile(cfg, *profileName); err != nil {
+			log.Fatal(msg(msgInvalidProfile, err))
+		}
+	}
+	narrow := func(m *MasterPlaylist) {
+		if *noHDR {
+			m.Variants = withoutHDR(m.Variants)
+		}
+		m.Variants = fpsPref.filter(m.Variants)
+		if profile != nil {
+			profile.apply(m)
+		}
+	}
+
+	opts := media.options(flag.Arg(0))
+
+	if *episodes != "" {
+		r, err := parseEpisodeRange(*episodes, *media.season)
+		if err != nil {
+			log.Fatal(msg(msgInvalidEpisodes, err))
+		}
+		if r.From != r.To {
+			if *play || expected > 0 || *audioDesc || *subs != "" || audioChannels > 0 {
+				log.Fatal(msg(msgRangeFlags))
+			}
+			if !resolveRange(opts, r, narrow, *durations) {
+				os.Exit(1)
+			}
+			return
+		}
+		// A single episode is resolved like any other title.
+		opts.Type, opts.Season, opts.Episode = TV, r.From.Season, r.From.Episode
+	}
+
+	master, err := opts.resolveRecorded(lastFailurePath())
+	if err != nil {
+		log.Fatal(msg(msgResolveFailed, err))
+	}
+
+	narrow(master)
+	var prefs Profile
+	if profile != nil {
+		prefs = *profile
+	}
+	if audioChannels > 0 {
+		prefs.AudioChannels = audioChannels
+	}
+	sel, err := prefs.selectTracks(master)
 	if err != nil {
-		log.Fatalf("failed to resolve: %v", err)
+		log.Fatal(msg(msgInvalidProfile, err))
+	}
+
+	printVariants(master, *durations)
+	if profile != nil {
+		fmt.Println(msg(msgProfile, profile.name, sel.Variant.label(), trackName(sel.Audio), trackName(sel.Subtitles)))
+	} else if a := sel.Audio; a != nil {
+		fmt.Println(msg(msgAudioTrack, a.Name, a.Language, a.Channels, a.URL))
+	}
+
+	if *audioDesc {
+		tracks := master.AudioDescriptions()
+		if len(tracks) == 0 {
+			log.Fatal(msg(msgNoAudioDesc, opts.IMDBID))
+		}
+		for _, r := range tracks {
+			fmt.Println(msg(msgAudioDesc, r.Name, r.Language, r.GroupID, r.URL))
+		}
+	}
+
+	if *subs != "" {
+		tracks := master.Subtitles(subsPref)
+		if len(tracks) == 0 {
+			log.Fatal(msg(msgNoSubtitles, *subs, opts.IMDBID))
+		}
+		for _, r := range tracks {
+			fmt.Println(msg(msgSubtitles, r.Name, r.Language, r.SubtitleKind(), r.GroupID, r.URL))
+		}
+	}
+
+	if expected > 0 {
+		v := master.bestVariant()
+		if sum, err := v.Summary(); err != nil {
+			log.Print(msg(msgSummaryFailed, v.Resolution, err))
+		} else if runtimeMismatch(sum.Duration, expected) {
+			log.Print(msg(msgRuntimeOff, formatTimestamp(sum.Duration), formatTimestamp(expected)))
+		}
 	}
 
-	for _, s := range streams {
-		fmt.Printf("Resolution: %s | Bandwidth: %s | URL: %s\n",
-			s.Resolution, s.Bandwidth, s.URL)
+	if *play {
+		player, err := findPlayer(cfg.Player)
+		if err != nil {
+			log.Fatal(msg(msgPlayFailed, err))
+		}
+		var tracks []Rendition
+		for _, r := range []*Rendition{sel.Audio, sel.Subtitles} {
+			if r != nil {
+				tracks = append(tracks, *r)
+			}
+		}
+		v := sel.Variant
+		fmt.Println(msg(msgPlaying, v.Resolution, player.Name, player.Path))
+		if err := player.Play(v, vidsrc.cdnHeaders(), tracks...); err != nil {
+			log.Fatal(msg(msgPlayFailed, err))
+		}
 	}
-}
\ No newline at end of file
+}
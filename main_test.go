@@ -0,0 +1,299 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// obfuscate is the inverse of Deobfuscate: base64, interleave filler, reverse.
+func obfuscate(plain string) string {
+	return obfuscateWith(base64.StdEncoding, plain)
+}
+
+func obfuscateWith(enc *base64.Encoding, plain string) string {
+	b64 := []rune(enc.EncodeToString([]byte(plain)))
+	var spread []rune
+	for i, r := range b64 {
+		if i > 0 {
+			spread = append(spread, 'x')
+		}
+		spread = append(spread, r)
+	}
+	for i, j := 0, len(spread)-1; i < j; i, j = i+1, j-1 {
+		spread[i], spread[j] = spread[j], spread[i]
+	}
+	return string(spread)
+}
+
+func TestParseAttributes(t *testing.T) {
+	tests := []struct {
+		line string
+		want map[string]string
+	}{
+		{
+			line: "#EXT-X-STREAM-INF:BANDWIDTH=5000000,RESOLUTION=1920x1080",
+			want: map[string]string{"BANDWIDTH": "5000000", "RESOLUTION": "1920x1080"},
+		},
+		{
+			line: `#EXT-X-STREAM-INF:BANDWIDTH=2800000,CODECS="avc1.4d401f,mp4a.40.2",RESOLUTION=1280x720`,
+			want: map[string]string{"BANDWIDTH": "2800000", "CODECS": "avc1.4d401f,mp4a.40.2", "RESOLUTION": "1280x720"},
+		},
+		{
+			line: `#EXT-X-MEDIA:TYPE=AUDIO,URI="https://cdn.example.test/a.m3u8?x=1,2",NAME="English"`,
+			want: map[string]string{"TYPE": "AUDIO", "URI": "https://cdn.example.test/a.m3u8?x=1,2", "NAME": "English"},
+		},
+		{
+			line: `#EXT-X-STREAM-INF:CODECS="unterminated,BANDWIDTH=1`,
+			want: map[string]string{"CODECS": "unterminated,BANDWIDTH=1"},
+		},
+		{
+			line: "#EXT-X-STREAM-INF:GARBAGE,BANDWIDTH=1",
+			want: map[string]string{"BANDWIDTH": "1"},
+		},
+		{
+			line: "#EXT-X-STREAM-INF",
+			want: map[string]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		got := parseAttributes(tt.line)
+		if len(got) != len(tt.want) {
+			t.Errorf("parseAttributes(%q) = %v, want %v", tt.line, got, tt.want)
+			continue
+		}
+		for k, v := range tt.want {
+			if got[k] != v {
+				t.Errorf("parseAttributes(%q)[%s] = %q, want %q", tt.line, k, got[k], v)
+			}
+		}
+	}
+}
+
+func TestDeobfuscateBase64Variants(t *testing.T) {
+	// "?>?" encodes to "Pz4/", which differs between the two alphabets.
+	plain := "https://cdn.example.test/pl/master.m3u8?q=?>?"
+	encodings := map[string]*base64.Encoding{
+		"std":        base64.StdEncoding,
+		"raw-std":    base64.RawStdEncoding,
+		"url":        base64.URLEncoding,
+		"raw-url":    base64.RawURLEncoding,
+		"whitespace": base64.StdEncoding,
+	}
+	for name, enc := range encodings {
+		blob := obfuscateWith(enc, plain)
+		if name == "whitespace" {
+			// Whitespace in the base64 text, i.e. at even positions of the reversed blob.
+			blob = "\nx" + blob
+		}
+		got, err := Deobfuscate(blob)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if got != plain {
+			t.Errorf("%s: Deobfuscate = %q, want %q", name, got, plain)
+		}
+	}
+}
+
+func TestDeobfuscateHardening(t *testing.T) {
+	plain := "https://cdn.example.test/pl/master.m3u8?a=1"
+	blob := obfuscate(plain) // base64 text ends in "=", the first rune of the blob
+
+	tests := map[string]string{
+		// A filler rune appended makes the blob even-length; reversed, the
+		// payload sits on the odd positions and the even ones are filler.
+		"even length": blob + "x",
+		// Padding escaped as an entity, as seen in double-escaped pages.
+		"entity":       "&#61;" + blob[1:],
+		"named entity": strings.ReplaceAll(blob, "x", "&amp;"),
+		// Filler outside the BMP is one rune but four bytes.
+		"astral filler": strings.ReplaceAll(blob, "x", "\U0001F600"),
+	}
+	for name, in := range tests {
+		got, err := Deobfuscate(in)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if got != plain {
+			t.Errorf("%s: Deobfuscate = %q, want %q", name, got, plain)
+		}
+	}
+}
+
+func TestDecodeHiddenPayloadPicksPlausibleDiv(t *testing.T) {
+	want := "https://cdn.example.test/pl/master.m3u8"
+	page := `<html><body>
+<div style="display:none;">menu</div>
+<div style="display: none">` + obfuscate("not a url") + `</div>
+<div style="display:none;" id="x9">` + obfuscate(want) + `</div>
+<div style="color:red">` + obfuscate("https://other.test/a.m3u8") + `</div>
+</body></html>`
+
+	decoded, err := decodeHiddenPayload(page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := validateStreamURL(decoded, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("decodeHiddenPayload = %q, want %q", got, want)
+	}
+
+	if _, err := decodeHiddenPayload(`<div style="display:none;">menu</div>`); err == nil {
+		t.Error("decodeHiddenPayload succeeded with only a decorative div")
+	}
+}
+
+func TestValidateStreamURL(t *testing.T) {
+	tests := []struct {
+		decoded      string
+		placeholders map[string]string
+		want         string
+	}{
+		{"https://cdn.example.test/pl/master.m3u8", nil, "https://cdn.example.test/pl/master.m3u8"},
+		{"https://a.{v1}/pl/list.m3u8 or https://b.example.test/pl/list.m3u8", nil, "https://b.example.test/pl/list.m3u8"},
+		{"https://a.{v1}/pl/list.m3u8 or https://b.{v2}/pl/list.m3u8", map[string]string{"v2": "example.test"}, "https://b.example.test/pl/list.m3u8"},
+		{"https://cdn.example.test/video.mp4?token=1", nil, "https://cdn.example.test/video.mp4?token=1"},
+	}
+	for _, tt := range tests {
+		got, err := validateStreamURL(tt.decoded, tt.placeholders)
+		if err != nil || got != tt.want {
+			t.Errorf("validateStreamURL(%q) = %q, %v; want %q", tt.decoded, got, err, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"", "not a url", "javascript:alert(1)", "https://cdn.example.test/index.html", "//cdn.example.test/a.m3u8", "https://a.{v1}/pl/list.m3u8"} {
+		if _, err := validateStreamURL(bad, nil); !errors.Is(err, errNonURL) {
+			t.Errorf("validateStreamURL(%q) error = %v, want errNonURL", bad, err)
+		}
+	}
+}
+
+func TestValidateStreamURLGolden(t *testing.T) {
+	golden, err := os.ReadFile(filepath.Join("testdata", "deobfuscate", "reverse-stride", "example-prorcp.golden"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := validateStreamURL(strings.TrimSpace(string(golden)), map[string]string{"v1": "example.test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(got, "https://tmstr1.example.test/pl/") || strings.Contains(got, " or ") {
+		t.Errorf("validateStreamURL picked %q, want the first {v1} alternative", got)
+	}
+}
+
+func FuzzDeobfuscate(f *testing.F) {
+	f.Add("")
+	f.Add("a")
+	f.Add("=x8xGxbxsxVxGxa")
+	f.Add("\xff\xfe")
+	f.Add(obfuscate("https://cdn.example.test/pl/master.m3u8"))
+	inputs, _ := filepath.Glob(filepath.Join("testdata", "deobfuscate", "*", "*.in"))
+	for _, in := range inputs {
+		if blob, err := os.ReadFile(in); err == nil {
+			f.Add(strings.TrimSpace(string(blob)))
+		}
+	}
+
+	f.Fuzz(func(t *testing.T, blob string) {
+		// Must return an error rather than panic on any input.
+		Deobfuscate(blob)
+		decodePayload(blob)
+	})
+}
+
+func FuzzDeobfuscateRoundTrip(f *testing.F) {
+	f.Add("https://cdn.example.test/pl/master.m3u8")
+	f.Add("")
+	f.Add("ünïcode")
+
+	f.Fuzz(func(t *testing.T, plain string) {
+		if !utf8.ValidString(plain) {
+			t.Skip()
+		}
+		got, err := Deobfuscate(obfuscate(plain))
+		if err != nil {
+			t.Fatalf("Deobfuscate(obfuscate(%q)): %v", plain, err)
+		}
+		if got != plain {
+			t.Fatalf("round trip of %q = %q", plain, got)
+		}
+	})
+}
+
+func FuzzParseAttributes(f *testing.F) {
+	f.Add("#EXT-X-STREAM-INF:BANDWIDTH=5000000,RESOLUTION=1920x1080")
+	f.Add(`#EXT-X-STREAM-INF:CODECS="avc1.4d401f,mp4a.40.2",BANDWIDTH=1`)
+	f.Add(`#EXT-X-MEDIA:URI="`)
+	f.Add("=,=,\"")
+
+	f.Fuzz(func(t *testing.T, line string) {
+		for k := range parseAttributes(line) {
+			if k == "" {
+				t.Fatalf("parseAttributes(%q) produced an empty key", line)
+			}
+		}
+	})
+}
+
+func FuzzParseAttributesQuoted(f *testing.F) {
+	f.Add("CODECS", "avc1.4d401f,mp4a.40.2")
+	f.Add("URI", "https://cdn.example.test/a.m3u8?x=1,y=2")
+
+	f.Fuzz(func(t *testing.T, key, val string) {
+		// Attribute names are [A-Z0-9-]; quoted values cannot contain quotes.
+		if key == "" || strings.Trim(key, "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-") != "" || strings.Contains(val, `"`) {
+			t.Skip()
+		}
+		line := `#EXT-X-MEDIA:TYPE=AUDIO,` + key + `="` + val + `",DEFAULT=YES`
+		attrs := parseAttributes(line)
+		if attrs[key] != val {
+			t.Fatalf("parseAttributes(%q)[%q] = %q, want %q", line, key, attrs[key], val)
+		}
+		if key != "DEFAULT" && attrs["DEFAULT"] != "YES" {
+			t.Fatalf("parseAttributes(%q) lost the attribute after the quoted value: %v", line, attrs)
+		}
+	})
+}
+
+func TestExtractServers(t *testing.T) {
+	page := `<iframe id="player_iframe" src="//rcp.example.test/rcp/aaa"></iframe>
+<div class="servers"><div class="serversList">
+	<div class="server" data-hash="aaa">CloudStream Pro</div>
+	<div class="server" data-hash="bbb">2Embed</div>
+	<div class="server" data-hash="">Broken</div>
+	<div class="server" data-hash="ccc"></div>
+</div></div>`
+	got, err := extractServers(page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []server{
+		{Name: "CloudStream Pro", RCPURL: "//rcp.example.test/rcp/aaa"},
+		{Name: "2Embed", RCPURL: "//rcp.example.test/rcp/bbb"},
+		{Name: "server 3", RCPURL: "//rcp.example.test/rcp/ccc"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("extractServers = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("server %d = %+v, want %+v", i+1, got[i], want[i])
+		}
+	}
+
+	if _, err := extractServers("<html></html>"); err == nil {
+		t.Error("extractServers succeeded on a page without servers")
+	}
+}
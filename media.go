@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MediaSummary describes the segments of one variant's media playlist.
+type MediaSummary struct {
+	Segments int
+	Duration time.Duration
+	Ended    bool // has EXT-X-ENDLIST, i.e. the playlist is complete
+}
+
+// shortVariantDuration is the length below which a variant is more likely a
+// trailer or a truncated upload than the feature.
+const shortVariantDuration = 10 * time.Minute
+
+// parseMediaPlaylist totals the EXTINF durations of a media playlist.
+func parseMediaPlaylist(body string) (MediaSummary, error) {
+	var s MediaSummary
+	var total float64
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "#EXT-X-STREAM-INF"):
+			return s, fmt.Errorf("expected a media playlist, got a master playlist")
+		case strings.HasPrefix(line, "#EXTINF:"):
+			dur, _, _ := strings.Cut(strings.TrimPrefix(line, "#EXTINF:"), ",")
+			v, err := strconv.ParseFloat(strings.TrimSpace(dur), 64)
+			if err != nil {
+				return s, fmt.Errorf("invalid segment duration %q: %w", dur, err)
+			}
+			total += v
+			s.Segments++
+		case line == "#EXT-X-ENDLIST":
+			s.Ended = true
+		}
+	}
+	s.Duration = secondsDuration(total)
+	return s, nil
+}
+
+// Summary fetches the variant's media playlist and summarizes it.
+func (v StreamVariant) Summary() (MediaSummary, error) {
+	body, err := fetchPlaylist(v.URL)
+	if err != nil {
+		return MediaSummary{}, err
+	}
+	s, err := parseMediaPlaylist(body)
+	if err != nil {
+		return s, fmt.Errorf("media playlist %q: %w", v.URL, err)
+	}
+	return s, nil
+}
+
+// runtimeTolerance is how far, as a fraction of the expected runtime, a
+// playlist may be off before it is reported as possibly the wrong title.
+const runtimeTolerance = 0.15
+
+// parseRuntime reads an expected runtime given as a duration ("2h19m") or
+// as plain minutes ("139").
+func parseRuntime(s string) (time.Duration, error) {
+	if min, err := strconv.Atoi(s); err == nil && min > 0 {
+		return time.Duration(min) * time.Minute, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid runtime %q: want minutes or a duration like 2h19m", s)
+	}
+	return d, nil
+}
+
+// runtimeMismatch reports whether got differs from want by more than
+// runtimeTolerance.
+func runtimeMismatch(got, want time.Duration) bool {
+	diff := got - want
+	if diff < 0 {
+		diff = -diff
+	}
+	return float64(diff) > runtimeTolerance*float64(want)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseMediaPlaylist(t *testing.T) {
+	body := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:6
+#EXTINF:6.000,
+seg-0.ts
+#EXTINF:6.000,
+seg-1.ts
+#EXTINF:4.500,title
+seg-2.ts
+#EXT-X-ENDLIST
+`
+	s, err := parseMediaPlaylist(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := MediaSummary{Segments: 3, Duration: 16500 * time.Millisecond, Ended: true}
+	if s != want {
+		t.Errorf("parseMediaPlaylist = %+v, want %+v", s, want)
+	}
+
+	if _, err := parseMediaPlaylist("#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=1\na.m3u8\n"); err == nil {
+		t.Error("parseMediaPlaylist accepted a master playlist")
+	}
+	if _, err := parseMediaPlaylist("#EXTM3U\n#EXTINF:abc,\nseg.ts\n"); err == nil {
+		t.Error("parseMediaPlaylist accepted a bad EXTINF duration")
+	}
+}
+
+func TestRuntimeCheck(t *testing.T) {
+	for in, want := range map[string]time.Duration{"139": 139 * time.Minute, "2h19m": 139 * time.Minute} {
+		if got, err := parseRuntime(in); err != nil || got != want {
+			t.Errorf("parseRuntime(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "0", "-5", "soon"} {
+		if _, err := parseRuntime(in); err == nil {
+			t.Errorf("parseRuntime(%q) succeeded", in)
+		}
+	}
+
+	want := 139 * time.Minute
+	if runtimeMismatch(135*time.Minute, want) {
+		t.Error("a few minutes off was reported as a mismatch")
+	}
+	if !runtimeMismatch(95*time.Minute, want) {
+		t.Error("a 44 minute difference was not reported")
+	}
+}
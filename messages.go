@@ -0,0 +1,333 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// User-facing CLI strings: what the CLI prints as its result, warnings about
+// the result (logged with log.Print so they go to stderr), and fatal errors.
+// Diagnostic log lines that trace the pipeline stay in English.
+// Flag descriptions also stay in English: they are listed under the
+// translated "Flags:" heading next to the flag names they document, and the
+// flag package prints them as registered.
+const (
+	msgUsage         = "usage"
+	msgFlags         = "flags"
+	msgVariant       = "variant"
+	msgVariantHDR    = "variant-hdr"
+	msgAudioDesc     = "audio-desc"
+	msgSubtitles     = "subtitles"
+	msgNoAudioDesc   = "no-audio-desc"
+	msgNoSubtitles   = "no-subtitles"
+	msgInvalidSubs   = "invalid-subs"
+	msgLoadConfig    = "load-config"
+	msgOpenCache     = "open-cache"
+	msgResolveFailed = "resolve-failed"
+	msgBackupFailed  = "backup-failed"
+	msgPlaying       = "playing"
+	msgPlayFailed    = "play-failed"
+
+	msgScreenshotSaved  = "screenshot-saved"
+	msgScreenshotFailed = "screenshot-failed"
+	msgProbeVideo       = "probe-video"
+	msgProbeAudio       = "probe-audio"
+	msgProbeDuration    = "probe-duration"
+	msgProbeFailed      = "probe-failed"
+
+	msgVariantSummary = "variant-summary"
+	msgShortVariant   = "short-variant"
+	msgSummaryFailed  = "summary-failed"
+	msgLowBitrate     = "low-bitrate"
+	msgRuntimeOff     = "runtime-mismatch"
+	msgInvalidRuntime = "invalid-runtime"
+
+	msgEpisode         = "episode"
+	msgEpisodeFailed   = "episode-failed"
+	msgInvalidEpisodes = "invalid-episodes"
+	msgRangeFlags      = "range-flags"
+
+	msgDeobfuscateFailed = "deobfuscate-failed"
+	msgExtractFailed     = "extract-failed"
+	msgReportFailed      = "report-failed"
+
+	msgBackupUsage      = "backup-usage"
+	msgUnknownBackupCmd = "unknown-backup-command"
+	msgRestoreUsage     = "restore-usage"
+	msgDeobfuscateUsage = "deobfuscate-usage"
+	msgExtractUsage     = "extract-usage"
+	msgProbeUsage       = "probe-usage"
+	msgScreenshotUsage  = "screenshot-usage"
+
+	msgNoMatches      = "no-matches"
+	msgExtractBanner  = "extract-banner"
+	msgExtractAttr    = "extract-attr-usage"
+	msgExtractUnknown = "extract-unknown"
+	msgExtractError   = "extract-error"
+
+	msgStatusFailed     = "status-failed"
+	msgLastFailure      = "last-failure"
+	msgNoLastFailure    = "no-last-failure"
+	msgRemoteStatus     = "remote-status"
+	msgStatusWidespread = "status-widespread"
+	msgStatusLocal      = "status-local"
+	msgStatusUnknown    = "status-unknown"
+
+	msgProfile        = "profile"
+	msgInvalidProfile = "invalid-profile"
+	msgInvalidFPS     = "invalid-fps"
+
+	msgAudioTrack      = "audio-track"
+	msgInvalidChannels = "invalid-channels"
+)
+
+// catalogs maps a language to its translations. English is the fallback for
+// languages and keys that have no entry.
+var catalogs = map[string]map[string]string{
+	"en": {
+		msgUsage:         "Usage: film-cli [flags] <imdb-id>\n       film-cli backup create|restore [flags]\n       film-cli screenshot [-at HH:MM:SS] [flags] <imdb-id>\n       film-cli probe [-json] [flags] <imdb-id>\n       film-cli deobfuscate [-scheme auto] [file]\n       film-cli extract -url <page> [-selector css] [-regex re]\n       film-cli report [-o file]\n       film-cli status [-remote]",
+		msgFlags:         "Flags:",
+		msgVariant:       "Resolution: %s | Bandwidth: %s | URL: %s",
+		msgVariantHDR:    "Resolution: %s | Bandwidth: %s | Range: %s | URL: %s",
+		msgAudioDesc:     "Audio description: %s | Language: %s | Group: %s | URL: %s",
+		msgSubtitles:     "Subtitles: %s | Language: %s | Kind: %s | Group: %s | URL: %s",
+		msgNoAudioDesc:   "no audio-description track available for %s",
+		msgNoSubtitles:   "no subtitle track matching %q available for %s",
+		msgInvalidSubs:   "invalid -subs: %v",
+		msgLoadConfig:    "failed to load config: %v",
+		msgOpenCache:     "failed to open cache: %v",
+		msgResolveFailed: "failed to resolve: %v",
+		msgBackupFailed:  "backup failed: %v",
+		msgPlaying:       "Playing %s with %s (%s)",
+		msgPlayFailed:    "failed to play: %v",
+
+		msgScreenshotSaved:  "Saved screenshot to %s",
+		msgScreenshotFailed: "screenshot failed: %v",
+		msgProbeVideo:       "Video: %s %dx%d @ %g fps",
+		msgProbeAudio:       "Audio: %s, %d channels",
+		msgProbeDuration:    "Duration: %s",
+		msgProbeFailed:      "probe failed: %v",
+
+		msgVariantSummary: "Resolution: %s | Duration: %s | Segments: %d",
+		msgShortVariant:   "variant %s is only %s long; it may be a trailer or broken",
+		msgSummaryFailed:  "cannot read media playlist for %s: %v",
+		msgLowBitrate:     "variant %s has only %d kbps; it may be a cam rip or mislabeled",
+		msgRuntimeOff:     "stream runs %s but %s was expected; the provider may be serving the wrong title",
+		msgInvalidRuntime: "invalid -runtime: %v",
+
+		msgEpisode:         "== %s ==",
+		msgEpisodeFailed:   "%s: %v",
+		msgInvalidEpisodes: "invalid -e: %v",
+		msgRangeFlags:      "-play, -runtime, -audio-desc, -subs and -audio-channels work on a single title, not an episode range",
+
+		msgDeobfuscateFailed: "deobfuscate failed: %v",
+		msgExtractFailed:     "extract failed: %v",
+		msgReportFailed:      "report failed: %v",
+
+		msgBackupUsage:      "usage: film-cli backup create|restore [flags]",
+		msgUnknownBackupCmd: "unknown backup command %q; want create or restore",
+		msgRestoreUsage:     "usage: film-cli backup restore [-force] <archive>",
+		msgDeobfuscateUsage: "usage: film-cli deobfuscate [-scheme name] [file]",
+		msgExtractUsage:     "usage: film-cli extract -url <page> | -file <path> [-selector css] [-attr name] [-regex re]",
+		msgProbeUsage:       "usage: film-cli probe [-json] [flags] <imdb-id>",
+		msgScreenshotUsage:  "usage: film-cli screenshot [-at HH:MM:SS] [-o file] [flags] <imdb-id>",
+
+		msgNoMatches:      "(no matches)",
+		msgExtractBanner:  "Page loaded (%d bytes). Commands: css <selector>, attr <selector> <attribute>, re <regex>; Ctrl-D to quit.",
+		msgExtractAttr:    "usage: attr <selector> <attribute>",
+		msgExtractUnknown: "unknown command %q",
+		msgExtractError:   "error: %v",
+
+		msgStatusFailed:     "status check failed: %v",
+		msgLastFailure:      "Last failed resolution: %s, %s: %s",
+		msgNoLastFailure:    "No failed resolution recorded.",
+		msgRemoteStatus:     "%s: %d of %d recent community reports succeeded",
+		msgStatusWidespread: "The provider is failing for most users; wait for it to recover or for a config update.",
+		msgStatusLocal:      "The provider works for most users; the problem is probably your network or config.",
+		msgStatusUnknown:    "Too few recent reports to tell whether the problem is widespread.",
+
+		msgProfile:        "Profile %s: %s | Audio: %s | Subtitles: %s",
+		msgInvalidProfile: "invalid -profile: %v",
+		msgInvalidFPS:     "invalid -fps: %v",
+
+		msgAudioTrack:      "Audio: %s | Language: %s | Channels: %s | URL: %s",
+		msgInvalidChannels: "invalid -audio-channels: %v",
+	},
+	"es": {
+		msgUsage:         "Uso: film-cli [opciones] <id-imdb>\n     film-cli backup create|restore [opciones]\n     film-cli screenshot [-at HH:MM:SS] [opciones] <id-imdb>\n     film-cli probe [-json] [opciones] <id-imdb>\n     film-cli deobfuscate [-scheme auto] [archivo]\n     film-cli extract -url <página> [-selector css] [-regex re]\n     film-cli report [-o archivo]\n     film-cli status [-remote]",
+		msgFlags:         "Opciones:",
+		msgVariant:       "Resolución: %s | Ancho de banda: %s | URL: %s",
+		msgVariantHDR:    "Resolución: %s | Ancho de banda: %s | Rango: %s | URL: %s",
+		msgAudioDesc:     "Audiodescripción: %s | Idioma: %s | Grupo: %s | URL: %s",
+		msgSubtitles:     "Subtítulos: %s | Idioma: %s | Tipo: %s | Grupo: %s | URL: %s",
+		msgNoAudioDesc:   "no hay pista de audiodescripción para %s",
+		msgNoSubtitles:   "no hay subtítulos que coincidan con %q para %s",
+		msgInvalidSubs:   "-subs no válido: %v",
+		msgLoadConfig:    "no se pudo cargar la configuración: %v",
+		msgOpenCache:     "no se pudo abrir la caché: %v",
+		msgResolveFailed: "no se pudo resolver: %v",
+		msgBackupFailed:  "la copia de seguridad falló: %v",
+		msgPlaying:       "Reproduciendo %s con %s (%s)",
+		msgPlayFailed:    "no se pudo reproducir: %v",
+
+		msgScreenshotSaved:  "Captura guardada en %s",
+		msgScreenshotFailed: "la captura falló: %v",
+		msgProbeVideo:       "Vídeo: %s %dx%d a %g fps",
+		msgProbeAudio:       "Audio: %s, %d canales",
+		msgProbeDuration:    "Duración: %s",
+		msgProbeFailed:      "el análisis falló: %v",
+
+		msgVariantSummary: "Resolución: %s | Duración: %s | Segmentos: %d",
+		msgShortVariant:   "la variante %s solo dura %s; puede ser un tráiler o estar dañada",
+		msgSummaryFailed:  "no se pudo leer la lista de medios de %s: %v",
+		msgLowBitrate:     "la variante %s solo tiene %d kbps; puede ser una grabación de cine o estar mal etiquetada",
+		msgRuntimeOff:     "el vídeo dura %s pero se esperaba %s; el proveedor puede estar sirviendo otro título",
+		msgInvalidRuntime: "-runtime no válido: %v",
+
+		msgEpisode:         "== %s ==",
+		msgEpisodeFailed:   "%s: %v",
+		msgInvalidEpisodes: "-e no válido: %v",
+		msgRangeFlags:      "-play, -runtime, -audio-desc, -subs y -audio-channels funcionan con un solo título, no con un rango de episodios",
+
+		msgDeobfuscateFailed: "la decodificación falló: %v",
+		msgExtractFailed:     "la extracción falló: %v",
+		msgReportFailed:      "el informe falló: %v",
+
+		msgBackupUsage:      "uso: film-cli backup create|restore [opciones]",
+		msgUnknownBackupCmd: "comando de copia de seguridad desconocido %q; usa create o restore",
+		msgRestoreUsage:     "uso: film-cli backup restore [-force] <archivo>",
+		msgDeobfuscateUsage: "uso: film-cli deobfuscate [-scheme nombre] [archivo]",
+		msgExtractUsage:     "uso: film-cli extract -url <página> | -file <ruta> [-selector css] [-attr nombre] [-regex re]",
+		msgProbeUsage:       "uso: film-cli probe [-json] [opciones] <id-imdb>",
+		msgScreenshotUsage:  "uso: film-cli screenshot [-at HH:MM:SS] [-o archivo] [opciones] <id-imdb>",
+
+		msgNoMatches:      "(sin coincidencias)",
+		msgExtractBanner:  "Página cargada (%d bytes). Comandos: css <selector>, attr <selector> <atributo>, re <regex>; Ctrl-D para salir.",
+		msgExtractAttr:    "uso: attr <selector> <atributo>",
+		msgExtractUnknown: "comando desconocido %q",
+		msgExtractError:   "error: %v",
+
+		msgStatusFailed:     "la comprobación de estado falló: %v",
+		msgLastFailure:      "Última resolución fallida: %s, %s: %s",
+		msgNoLastFailure:    "No hay ninguna resolución fallida registrada.",
+		msgRemoteStatus:     "%s: %d de %d informes recientes de la comunidad tuvieron éxito",
+		msgStatusWidespread: "El proveedor falla para la mayoría de usuarios; espera a que se recupere o a una actualización de la configuración.",
+		msgStatusLocal:      "El proveedor funciona para la mayoría de usuarios; el problema probablemente es tu red o tu configuración.",
+		msgStatusUnknown:    "Hay muy pocos informes recientes para saber si el problema es general.",
+
+		msgProfile:        "Perfil %s: %s | Audio: %s | Subtítulos: %s",
+		msgInvalidProfile: "-profile no válido: %v",
+		msgInvalidFPS:     "-fps no válido: %v",
+
+		msgAudioTrack:      "Audio: %s | Idioma: %s | Canales: %s | URL: %s",
+		msgInvalidChannels: "-audio-channels no válido: %v",
+	},
+	"de": {
+		msgUsage:         "Aufruf: film-cli [Optionen] <IMDb-ID>\n        film-cli backup create|restore [Optionen]\n        film-cli screenshot [-at HH:MM:SS] [Optionen] <IMDb-ID>\n        film-cli probe [-json] [Optionen] <IMDb-ID>\n        film-cli deobfuscate [-scheme auto] [Datei]\n        film-cli extract -url <Seite> [-selector css] [-regex re]\n        film-cli report [-o Datei]\n        film-cli status [-remote]",
+		msgFlags:         "Optionen:",
+		msgVariant:       "Auflösung: %s | Bandbreite: %s | URL: %s",
+		msgVariantHDR:    "Auflösung: %s | Bandbreite: %s | Dynamikumfang: %s | URL: %s",
+		msgAudioDesc:     "Audiodeskription: %s | Sprache: %s | Gruppe: %s | URL: %s",
+		msgSubtitles:     "Untertitel: %s | Sprache: %s | Art: %s | Gruppe: %s | URL: %s",
+		msgNoAudioDesc:   "keine Audiodeskription für %s verfügbar",
+		msgNoSubtitles:   "keine Untertitel passend zu %q für %s verfügbar",
+		msgInvalidSubs:   "ungültiges -subs: %v",
+		msgLoadConfig:    "Konfiguration konnte nicht geladen werden: %v",
+		msgOpenCache:     "Cache konnte nicht geöffnet werden: %v",
+		msgResolveFailed: "Auflösung fehlgeschlagen: %v",
+		msgBackupFailed:  "Sicherung fehlgeschlagen: %v",
+		msgPlaying:       "Spiele %s mit %s (%s) ab",
+		msgPlayFailed:    "Wiedergabe fehlgeschlagen: %v",
+
+		msgScreenshotSaved:  "Bildschirmfoto gespeichert unter %s",
+		msgScreenshotFailed: "Bildschirmfoto fehlgeschlagen: %v",
+		msgProbeVideo:       "Video: %s %dx%d bei %g fps",
+		msgProbeAudio:       "Audio: %s, %d Kanäle",
+		msgProbeDuration:    "Dauer: %s",
+		msgProbeFailed:      "Analyse fehlgeschlagen: %v",
+
+		msgVariantSummary: "Auflösung: %s | Dauer: %s | Segmente: %d",
+		msgShortVariant:   "Variante %s ist nur %s lang; vielleicht ein Trailer oder defekt",
+		msgSummaryFailed:  "Medien-Playlist für %s nicht lesbar: %v",
+		msgLowBitrate:     "Variante %s hat nur %d kbit/s; vielleicht eine Kinoaufnahme oder falsch beschriftet",
+		msgRuntimeOff:     "Stream dauert %s, erwartet waren %s; der Anbieter liefert vielleicht den falschen Titel",
+		msgInvalidRuntime: "ungültiges -runtime: %v",
+
+		msgEpisode:         "== %s ==",
+		msgEpisodeFailed:   "%s: %v",
+		msgInvalidEpisodes: "ungültiges -e: %v",
+		msgRangeFlags:      "-play, -runtime, -audio-desc, -subs und -audio-channels gelten für einen einzelnen Titel, nicht für einen Episodenbereich",
+
+		msgDeobfuscateFailed: "Dekodierung fehlgeschlagen: %v",
+		msgExtractFailed:     "Extraktion fehlgeschlagen: %v",
+		msgReportFailed:      "Bericht fehlgeschlagen: %v",
+
+		msgBackupUsage:      "Aufruf: film-cli backup create|restore [Optionen]",
+		msgUnknownBackupCmd: "unbekannter backup-Befehl %q; erwartet create oder restore",
+		msgRestoreUsage:     "Aufruf: film-cli backup restore [-force] <Archiv>",
+		msgDeobfuscateUsage: "Aufruf: film-cli deobfuscate [-scheme Name] [Datei]",
+		msgExtractUsage:     "Aufruf: film-cli extract -url <Seite> | -file <Pfad> [-selector css] [-attr Name] [-regex re]",
+		msgProbeUsage:       "Aufruf: film-cli probe [-json] [Optionen] <IMDb-ID>",
+		msgScreenshotUsage:  "Aufruf: film-cli screenshot [-at HH:MM:SS] [-o Datei] [Optionen] <IMDb-ID>",
+
+		msgNoMatches:      "(keine Treffer)",
+		msgExtractBanner:  "Seite geladen (%d Bytes). Befehle: css <Selektor>, attr <Selektor> <Attribut>, re <Regex>; Strg-D zum Beenden.",
+		msgExtractAttr:    "Aufruf: attr <Selektor> <Attribut>",
+		msgExtractUnknown: "unbekannter Befehl %q",
+		msgExtractError:   "Fehler: %v",
+
+		msgStatusFailed:     "Statusprüfung fehlgeschlagen: %v",
+		msgLastFailure:      "Letzte fehlgeschlagene Auflösung: %s, %s: %s",
+		msgNoLastFailure:    "Keine fehlgeschlagene Auflösung aufgezeichnet.",
+		msgRemoteStatus:     "%s: %d von %d aktuellen Community-Meldungen waren erfolgreich",
+		msgStatusWidespread: "Der Anbieter funktioniert für die meisten Nutzer nicht; warte auf seine Erholung oder ein Konfigurations-Update.",
+		msgStatusLocal:      "Der Anbieter funktioniert für die meisten Nutzer; das Problem liegt wahrscheinlich an deinem Netzwerk oder deiner Konfiguration.",
+		msgStatusUnknown:    "Zu wenige aktuelle Meldungen, um zu sagen, ob das Problem verbreitet ist.",
+
+		msgProfile:        "Profil %s: %s | Audio: %s | Untertitel: %s",
+		msgInvalidProfile: "ungültiges -profile: %v",
+		msgInvalidFPS:     "ungültiges -fps: %v",
+
+		msgAudioTrack:      "Audio: %s | Sprache: %s | Kanäle: %s | URL: %s",
+		msgInvalidChannels: "ungültiges -audio-channels: %v",
+	},
+}
+
+// language is the catalog used for output: LC_ALL, LC_MESSAGES or LANG at
+// startup, overridden by the config file's "language" in main.
+var language = languageFromEnv()
+
+// languageFromEnv returns the first supported language named by the locale
+// environment variables, or "en".
+func languageFromEnv() string {
+	for _, v := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if l, ok := supportedLanguage(os.Getenv(v)); ok {
+			return l
+		}
+	}
+	return "en"
+}
+
+// supportedLanguage reduces a locale such as "de_DE.UTF-8" or "es-MX" to its
+// language and reports whether there is a catalog for it.
+func supportedLanguage(locale string) (string, bool) {
+	l := strings.ToLower(locale)
+	if i := strings.IndexAny(l, "_-.@"); i >= 0 {
+		l = l[:i]
+	}
+	_, ok := catalogs[l]
+	return l, ok
+}
+
+// msg formats the message key in the current language.
+func msg(key string, args ...any) string {
+	format, ok := catalogs[language][key]
+	if !ok {
+		format, ok = catalogs["en"][key]
+	}
+	if !ok {
+		return fmt.Sprintf("%s %v", key, args)
+	}
+	return fmt.Sprintf(format, args...)
+}
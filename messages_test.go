@@ -0,0 +1,41 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCatalogsComplete(t *testing.T) {
+	for lang, c := range catalogs {
+		for key, en := range catalogs["en"] {
+			tr, ok := c[key]
+			if !ok {
+				t.Errorf("%s: missing message %q", lang, key)
+				continue
+			}
+			if strings.Count(tr, "%") != strings.Count(en, "%") {
+				t.Errorf("%s: message %q has different verbs from English: %q", lang, key, tr)
+			}
+		}
+	}
+}
+
+func TestSupportedLanguage(t *testing.T) {
+	tests := []struct {
+		locale string
+		want   string
+		ok     bool
+	}{
+		{"de_DE.UTF-8", "de", true},
+		{"es-MX", "es", true},
+		{"EN", "en", true},
+		{"C", "c", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := supportedLanguage(tt.locale)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("supportedLanguage(%q) = %q, %v; want %q, %v", tt.locale, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// Player is an external video player that can open an HLS URL.
+type Player struct {
+	Name string
+	Path string
+}
+
+// knownPlayer describes where to look for one supported player.
+type knownPlayer struct {
+	name      string
+	binaries  []string            // names looked up on PATH
+	locations map[string][]string // GOOS to install paths outside PATH
+}
+
+// knownPlayers is ordered by preference: mpv handles HLS best, IINA is mpv
+// with a macOS front end, and VLC is the fallback most people already have.
+var knownPlayers = []knownPlayer{
+	{
+		name:     "mpv",
+		binaries: []string{"mpv"},
+		locations: map[string][]string{
+			"darwin":  {"/Applications/mpv.app/Contents/MacOS/mpv", "/opt/homebrew/bin/mpv", "/usr/local/bin/mpv"},
+			"windows": {`%ProgramFiles%\mpv\mpv.exe`, `%LOCALAPPDATA%\Programs\mpv\mpv.exe`, `%USERPROFILE%\scoop\apps\mpv\current\mpv.exe`},
+		},
+	},
+	{
+		name:     "iina",
+		binaries: []string{"iina", "iina-cli"},
+		locations: map[string][]string{
+			"darwin": {"/Applications/IINA.app/Contents/MacOS/iina-cli"},
+		},
+	},
+	{
+		name:     "vlc",
+		binaries: []string{"vlc"},
+		locations: map[string][]string{
+			"darwin":  {"/Applications/VLC.app/Contents/MacOS/VLC"},
+			"windows": {`%ProgramFiles%\VideoLAN\VLC\vlc.exe`, `%ProgramFiles(x86)%\VideoLAN\VLC\vlc.exe`},
+		},
+	},
+}
+
+// findPlayer returns the configured player, or the best one found on PATH or
+// in the usual install locations when none is configured. A configured path
+// may use $VAR or %VAR% references.
+func findPlayer(configured string) (Player, error) {
+	if configured != "" {
+		path, err := exec.LookPath(os.ExpandEnv(expandWindowsEnv(configured)))
+		if err != nil {
+			return Player{}, fmt.Errorf("configured player %q: %w", configured, err)
+		}
+		return Player{Name: playerName(path), Path: path}, nil
+	}
+
+	for _, p := range knownPlayers {
+		for _, bin := range p.binaries {
+			if path, err := exec.LookPath(bin); err == nil {
+				return Player{Name: p.name, Path: path}, nil
+			}
+		}
+		for _, loc := range p.locations[runtime.GOOS] {
+			path := expandWindowsEnv(loc)
+			if fi, err := os.Stat(path); err == nil && !fi.IsDir() {
+				return Player{Name: p.name, Path: path}, nil
+			}
+		}
+	}
+	return Player{}, fmt.Errorf("no player found; install mpv, IINA or VLC, or set \"player\" in the config")
+}
+
+// playerName names the player at path, using the known player's name when
+// the binary is one of its, so that e.g. iina-cli gets IINA's options.
+func playerName(path string) string {
+	base := strings.ToLower(strings.TrimSuffix(filepath.Base(path), ".exe"))
+	for _, p := range knownPlayers {
+		for _, bin := range p.binaries {
+			if base == bin {
+				return p.name
+			}
+		}
+	}
+	return base
+}
+
+// expandWindowsEnv expands %VAR% references, which os.ExpandEnv does not know.
+func expandWindowsEnv(s string) string {
+	var b strings.Builder
+	for {
+		i := strings.IndexByte(s, '%')
+		j := -1
+		if i >= 0 {
+			j = strings.IndexByte(s[i+1:], '%')
+		}
+		if j < 0 {
+			b.WriteString(s)
+			return b.String()
+		}
+		b.WriteString(s[:i])
+		b.WriteString(os.Getenv(s[i+1 : i+1+j]))
+		s = s[i+j+2:]
+	}
+}
+
+// headerArgs returns the command-line options that make the player send
+// headers on its playlist and segment requests.
+func (p Player) headerArgs(headers map[string]string) []string {
+	if len(headers) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(headers))
+	for k := range headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	switch p.Name {
+	case "mpv", "iina":
+		var fields []string
+		for _, k := range keys {
+			fields = append(fields, k+": "+headers[k])
+		}
+		opt := "--http-header-fields="
+		if p.Name == "iina" {
+			opt = "--mpv-http-header-fields="
+		}
+		return []string{opt + strings.Join(fields, ",")}
+	case "vlc":
+		// VLC has no generic header option; the referer is what CDNs check most.
+		if ref := headers["Referer"]; ref != "" {
+			return []string{"--http-referrer=" + ref}
+		}
+	}
+	return nil
+}
+
+// trackArgs returns the options that load separate audio and subtitle
+// renditions alongside the video.
+func (p Player) trackArgs(tracks []Rendition) []string {
+	var args []string
+	for _, t := range tracks {
+		if t.URL == "" {
+			continue
+		}
+		switch {
+		case p.Name == "vlc":
+			args = append(args, "--input-slave="+t.URL)
+		case t.Type == "AUDIO" && p.Name == "iina":
+			args = append(args, "--mpv-audio-file="+t.URL)
+		case t.Type == "AUDIO":
+			args = append(args, "--audio-file="+t.URL)
+		case p.Name == "iina":
+			args = append(args, "--mpv-sub-file="+t.URL)
+		default:
+			args = append(args, "--sub-file="+t.URL)
+		}
+	}
+	return args
+}
+
+// hdrArgs returns the options for playing an HDR variant. mpv then tells
+// HDR displays what the stream carries instead of tone mapping it down;
+// on SDR displays it keeps tone mapping. VLC handles both on its own.
+func (p Player) hdrArgs(v StreamVariant) []string {
+	if !v.hdr() {
+		return nil
+	}
+	switch p.Name {
+	case "mpv":
+		return []string{"--target-colorspace-hint=yes"}
+	case "iina":
+		return []string{"--mpv-target-colorspace-hint=yes"}
+	}
+	return nil
+}
+
+// Play opens v in the player with any separate audio and subtitle tracks,
+// passing headers for its requests, and waits for it to exit.
+func (p Player) Play(v StreamVariant, headers map[string]string, tracks ...Rendition) error {
+	args := append(p.headerArgs(headers), p.hdrArgs(v)...)
+	args = append(args, p.trackArgs(tracks)...)
+	cmd := exec.Command(p.Path, append(args, v.URL)...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("running %s: %w", p.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func fakeBinary(t *testing.T, dir, name string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestFindPlayerPrefersMPV(t *testing.T) {
+	dir := t.TempDir()
+	fakeBinary(t, dir, "vlc")
+	t.Setenv("PATH", dir)
+
+	p, err := findPlayer("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != "vlc" {
+		t.Errorf("with only vlc on PATH, findPlayer chose %+v", p)
+	}
+
+	mpv := fakeBinary(t, dir, "mpv")
+	if p, err = findPlayer(""); err != nil || p.Path != mpv {
+		t.Errorf("with mpv on PATH, findPlayer = %+v, %v; want %s", p, err, mpv)
+	}
+}
+
+func TestFindPlayerConfigured(t *testing.T) {
+	dir := t.TempDir()
+	custom := fakeBinary(t, dir, "myplayer")
+	t.Setenv("PATH", t.TempDir())
+
+	p, err := findPlayer(custom)
+	if err != nil || p.Path != custom || p.Name != "myplayer" {
+		t.Errorf("findPlayer(%q) = %+v, %v", custom, p, err)
+	}
+	if _, err := findPlayer(filepath.Join(dir, "missing")); err == nil {
+		t.Error("findPlayer accepted a missing configured player")
+	}
+
+	// IINA's command-line binary gets IINA's options.
+	iina := fakeBinary(t, dir, "iina-cli")
+	if p, err := findPlayer(iina); err != nil || p.Name != "iina" {
+		t.Errorf("findPlayer(%q) = %+v, %v; want name iina", iina, p, err)
+	}
+}
+
+func TestFindPlayerConfiguredExpandsEnv(t *testing.T) {
+	dir := t.TempDir()
+	mpv := fakeBinary(t, dir, "mpv")
+	t.Setenv("PATH", t.TempDir())
+	t.Setenv("FILM_CLI_TEST_DIR", dir)
+
+	for _, configured := range []string{"%FILM_CLI_TEST_DIR%/mpv", "$FILM_CLI_TEST_DIR/mpv"} {
+		if p, err := findPlayer(configured); err != nil || p.Path != mpv || p.Name != "mpv" {
+			t.Errorf("findPlayer(%q) = %+v, %v; want %s", configured, p, err, mpv)
+		}
+	}
+}
+
+func TestExpandWindowsEnv(t *testing.T) {
+	t.Setenv("FILM_CLI_TEST_DIR", `C:\Apps`)
+	if got, want := expandWindowsEnv(`%FILM_CLI_TEST_DIR%\mpv\mpv.exe`), `C:\Apps\mpv\mpv.exe`; got != want {
+		t.Errorf("expandWindowsEnv = %q, want %q", got, want)
+	}
+	if got := expandWindowsEnv("100%"); got != "100%" {
+		t.Errorf("expandWindowsEnv(%q) = %q", "100%", got)
+	}
+}
+
+func TestPlayerHeaderArgs(t *testing.T) {
+	headers := map[string]string{"Referer": "https://cloudnestra.com/", "Origin": "https://cloudnestra.com"}
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"mpv", "--http-header-fields=Origin: https://cloudnestra.com,Referer: https://cloudnestra.com/"},
+		{"iina", "--mpv-http-header-fields=Origin: https://cloudnestra.com,Referer: https://cloudnestra.com/"},
+		{"vlc", "--http-referrer=https://cloudnestra.com/"},
+	}
+	for _, tt := range tests {
+		args := Player{Name: tt.name}.headerArgs(headers)
+		if len(args) != 1 || args[0] != tt.want {
+			t.Errorf("%s headerArgs = %q, want %q", tt.name, args, tt.want)
+		}
+	}
+	if args := (Player{Name: "mpv"}).headerArgs(nil); args != nil {
+		t.Errorf("headerArgs(nil) = %q, want none", args)
+	}
+}
+
+func TestPlayerTrackArgs(t *testing.T) {
+	tracks := []Rendition{
+		{Type: "AUDIO", URL: "https://cdn.example.test/a.m3u8"},
+		{Type: "AUDIO"}, // muxed into the variant
+		{Type: "SUBTITLES", URL: "https://cdn.example.test/s.m3u8"},
+	}
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"mpv", "--audio-file=https://cdn.example.test/a.m3u8 --sub-file=https://cdn.example.test/s.m3u8"},
+		{"iina", "--mpv-audio-file=https://cdn.example.test/a.m3u8 --mpv-sub-file=https://cdn.example.test/s.m3u8"},
+		{"vlc", "--input-slave=https://cdn.example.test/a.m3u8 --input-slave=https://cdn.example.test/s.m3u8"},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(Player{Name: tt.name}.trackArgs(tracks), " "); got != tt.want {
+			t.Errorf("%s trackArgs = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPlayerHDRArgs(t *testing.T) {
+	hdr := StreamVariant{VideoRange: "PQ"}
+	if got := (Player{Name: "mpv"}).hdrArgs(hdr); len(got) != 1 || got[0] != "--target-colorspace-hint=yes" {
+		t.Errorf("mpv hdrArgs = %q", got)
+	}
+	if got := (Player{Name: "iina"}).hdrArgs(hdr); len(got) != 1 || got[0] != "--mpv-target-colorspace-hint=yes" {
+		t.Errorf("iina hdrArgs = %q", got)
+	}
+	if got := (Player{Name: "vlc"}).hdrArgs(hdr); got != nil {
+		t.Errorf("vlc hdrArgs = %q, want none", got)
+	}
+	if got := (Player{Name: "mpv"}).hdrArgs(StreamVariant{VideoRange: "SDR"}); got != nil {
+		t.Errorf("hdrArgs for SDR = %q, want none", got)
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// MasterPlaylist is a parsed HLS master playlist.
+type MasterPlaylist struct {
+	URL        string
+	Variants   []StreamVariant
+	Renditions []Rendition // alternative audio and subtitle tracks
+}
+
+// Rendition is one EXT-X-MEDIA entry: an alternative audio, subtitle or
+// closed-caption track shared by the variants that reference its group.
+type Rendition struct {
+	Type            string // AUDIO, SUBTITLES, CLOSED-CAPTIONS or VIDEO
+	GroupID         string
+	Name            string
+	Language        string
+	Default         bool
+	Autoselect      bool
+	Forced          bool
+	Characteristics string // comma-separated UTIs, e.g. public.accessibility.describes-video
+	Channels        string
+	URL             string // absolute; empty for renditions muxed into the variant
+}
+
+// characteristicDescribesVideo marks audio renditions that carry audio description.
+const characteristicDescribesVideo = "public.accessibility.describes-video"
+
+func (r Rendition) hasCharacteristic(c string) bool {
+	for _, v := range strings.Split(r.Characteristics, ",") {
+		if strings.TrimSpace(v) == c {
+			return true
+		}
+	}
+	return false
+}
+
+// channelCount returns the number of audio channels from CHANNELS, which
+// may carry a suffix such as "16/JOC", or 0 if it is not given.
+func (r Rendition) channelCount() int {
+	count, _, _ := strings.Cut(r.Channels, "/")
+	n, _ := strconv.Atoi(count)
+	return n
+}
+
+// DescribesVideo reports whether r is an audio-description track for visually impaired viewers.
+func (r Rendition) DescribesVideo() bool {
+	return r.Type == "AUDIO" && r.hasCharacteristic(characteristicDescribesVideo)
+}
+
+// Characteristics that mark subtitles for the deaf and hard of hearing.
+const (
+	characteristicTranscribesDialog = "public.accessibility.transcribes-spoken-dialog"
+	characteristicDescribesMusic    = "public.accessibility.describes-music-and-sound"
+)
+
+// Subtitle kinds, as used in -subs preferences.
+const (
+	SubtitleRegular = "regular"
+	SubtitleSDH     = "sdh"
+	SubtitleForced  = "forced"
+)
+
+// SubtitleKind classifies a subtitle or caption rendition as forced, SDH or
+// regular. Forced wins over SDH; closed captions count as SDH.
+func (r Rendition) SubtitleKind() string {
+	switch {
+	case r.Forced:
+		return SubtitleForced
+	case r.Type == "CLOSED-CAPTIONS",
+		r.hasCharacteristic(characteristicTranscribesDialog),
+		r.hasCharacteristic(characteristicDescribesMusic):
+		return SubtitleSDH
+	default:
+		return SubtitleRegular
+	}
+}
+
+// SubtitlePref selects subtitle renditions by language and kind; empty
+// fields match anything.
+type SubtitlePref struct {
+	Language string
+	Kind     string
+}
+
+// parseSubtitlePref parses a -subs value of the form lang[:kind], e.g. "en:sdh".
+func parseSubtitlePref(s string) (SubtitlePref, error) {
+	lang, kind, _ := strings.Cut(s, ":")
+	p := SubtitlePref{Language: strings.TrimSpace(lang), Kind: strings.ToLower(strings.TrimSpace(kind))}
+	switch p.Kind {
+	case "", SubtitleRegular, SubtitleSDH, SubtitleForced:
+		return p, nil
+	default:
+		return p, fmt.Errorf("unknown subtitle kind %q (want regular, sdh or forced)", kind)
+	}
+}
+
+// matchesLanguage reports whether tag matches want, ignoring case and, when
+// want has no region, the region of tag ("en" matches "en-US").
+func matchesLanguage(tag, want string) bool {
+	if want == "" || strings.EqualFold(tag, want) {
+		return true
+	}
+	primary, _, _ := strings.Cut(tag, "-")
+	return !strings.Contains(want, "-") && strings.EqualFold(primary, want)
+}
+
+// Subtitles returns the subtitle and caption renditions matching p.
+func (m *MasterPlaylist) Subtitles(p SubtitlePref) []Rendition {
+	var out []Rendition
+	for _, r := range m.Renditions {
+		if r.Type != "SUBTITLES" && r.Type != "CLOSED-CAPTIONS" {
+			continue
+		}
+		if !matchesLanguage(r.Language, p.Language) {
+			continue
+		}
+		if p.Kind != "" && r.SubtitleKind() != p.Kind {
+			continue
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+// AudioDescriptions returns the audio-description renditions in the playlist.
+func (m *MasterPlaylist) AudioDescriptions() []Rendition {
+	var out []Rendition
+	for _, r := range m.Renditions {
+		if r.DescribesVideo() {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// bestVariant returns the variant with the highest bandwidth.
+func (m *MasterPlaylist) bestVariant() StreamVariant {
+	best, bestBW := m.Variants[0], -1
+	for _, v := range m.Variants {
+		if bw, err := strconv.Atoi(v.Bandwidth); err == nil && bw > bestBW {
+			best, bestBW = v, bw
+		}
+	}
+	return best
+}
+
+// parseMasterPlaylist reads the variants and renditions of a master playlist,
+// resolving their URIs against masterURL.
+func parseMasterPlaylist(masterURL, body string) (*MasterPlaylist, error) {
+	m := &MasterPlaylist{URL: masterURL}
+	lines := strings.Split(body, "\n")
+
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "#EXT-X-STREAM-INF"):
+			attrs := parseAttributes(line)
+			resolution := attrs["RESOLUTION"]
+			bandwidth := attrs["BANDWIDTH"]
+			if i+1 < len(lines) {
+				urlLine := strings.TrimSpace(lines[i+1])
+				if urlLine != "" && !strings.HasPrefix(urlLine, "#") {
+					abs := resolveRelativeURL(masterURL, urlLine)
+					variant := StreamVariant{
+						Resolution: resolution,
+						Bandwidth:  bandwidth,
+						URL:        abs,
+						Codecs:     attrs["CODECS"],
+						VideoRange: attrs["VIDEO-RANGE"],
+						FrameRate:  attrs["FRAME-RATE"],
+						Audio:      attrs["AUDIO"],
+						Subtitles:  attrs["SUBTITLES"],
+					}
+					m.Variants = append(m.Variants, variant)
+					log.Printf("Found variant: Resolution=%s, Bandwidth=%s", resolution, bandwidth)
+				}
+			}
+
+		case strings.HasPrefix(line, "#EXT-X-MEDIA:"):
+			attrs := parseAttributes(line)
+			r := Rendition{
+				Type:            attrs["TYPE"],
+				GroupID:         attrs["GROUP-ID"],
+				Name:            attrs["NAME"],
+				Language:        attrs["LANGUAGE"],
+				Default:         attrs["DEFAULT"] == "YES",
+				Autoselect:      attrs["AUTOSELECT"] == "YES",
+				Forced:          attrs["FORCED"] == "YES",
+				Characteristics: attrs["CHARACTERISTICS"],
+				Channels:        attrs["CHANNELS"],
+			}
+			if uri := attrs["URI"]; uri != "" {
+				r.URL = resolveRelativeURL(masterURL, uri)
+			}
+			m.Renditions = append(m.Renditions, r)
+			log.Printf("Found %s rendition: Name=%s, Language=%s", r.Type, r.Name, r.Language)
+		}
+	}
+
+	if len(m.Variants) == 0 {
+		return nil, fmt.Errorf("no stream variants found in master playlist %q", masterURL)
+	}
+
+	log.Printf("Found %d stream variants.", len(m.Variants))
+	return m, nil
+}
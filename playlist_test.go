@@ -0,0 +1,89 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const masterWithRenditions = `#EXTM3U
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aud",NAME="English",LANGUAGE="en",DEFAULT=YES,AUTOSELECT=YES,CHANNELS="2",URI="audio/en.m3u8"
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aud",NAME="English (AD)",LANGUAGE="en",CHARACTERISTICS="public.accessibility.describes-video",CHANNELS="2",URI="audio/en-ad.m3u8"
+#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="subs",NAME="English",LANGUAGE="en",URI="subs/en.m3u8"
+#EXT-X-STREAM-INF:BANDWIDTH=5000000,RESOLUTION=1920x1080,CODECS="avc1.640028,mp4a.40.2",AUDIO="aud",SUBTITLES="subs"
+1080/index.m3u8
+`
+
+func TestParseMasterPlaylistRenditions(t *testing.T) {
+	m, err := parseMasterPlaylist("https://cdn.example.test/pl/master.m3u8", masterWithRenditions)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(m.Variants) != 1 {
+		t.Fatalf("got %d variants, want 1", len(m.Variants))
+	}
+	v := m.Variants[0]
+	if v.Audio != "aud" || v.Subtitles != "subs" {
+		t.Errorf("variant groups = %q/%q, want aud/subs", v.Audio, v.Subtitles)
+	}
+
+	if len(m.Renditions) != 3 {
+		t.Fatalf("got %d renditions, want 3", len(m.Renditions))
+	}
+	ad := m.AudioDescriptions()
+	if len(ad) != 1 || ad[0].Name != "English (AD)" {
+		t.Fatalf("AudioDescriptions() = %+v, want the English (AD) track", ad)
+	}
+	if want := "https://cdn.example.test/pl/audio/en-ad.m3u8"; ad[0].URL != want {
+		t.Errorf("AD URL = %q, want %q", ad[0].URL, want)
+	}
+	if !m.Renditions[0].Default || m.Renditions[0].DescribesVideo() {
+		t.Errorf("first rendition = %+v, want default non-AD track", m.Renditions[0])
+	}
+}
+
+const masterWithSubtitles = `#EXTM3U
+#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="subs",NAME="English",LANGUAGE="en",URI="subs/en.m3u8"
+#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="subs",NAME="English (SDH)",LANGUAGE="en-US",CHARACTERISTICS="public.accessibility.transcribes-spoken-dialog,public.accessibility.describes-music-and-sound",URI="subs/en-sdh.m3u8"
+#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="subs",NAME="English (Forced)",LANGUAGE="en",FORCED=YES,URI="subs/en-forced.m3u8"
+#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="subs",NAME="Deutsch",LANGUAGE="de",URI="subs/de.m3u8"
+#EXT-X-STREAM-INF:BANDWIDTH=5000000,RESOLUTION=1920x1080,SUBTITLES="subs"
+1080/index.m3u8
+`
+
+func TestSubtitlesByPref(t *testing.T) {
+	m, err := parseMasterPlaylist("https://cdn.example.test/pl/master.m3u8", masterWithSubtitles)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		pref string
+		want []string
+	}{
+		{"", []string{"English", "English (SDH)", "English (Forced)", "Deutsch"}},
+		{"en", []string{"English", "English (SDH)", "English (Forced)"}},
+		{"en:sdh", []string{"English (SDH)"}},
+		{"en-us:sdh", []string{"English (SDH)"}},
+		{"en:forced", []string{"English (Forced)"}},
+		{"en:regular", []string{"English"}},
+		{"de:sdh", nil},
+	}
+	for _, tt := range tests {
+		p, err := parseSubtitlePref(tt.pref)
+		if err != nil {
+			t.Fatalf("parseSubtitlePref(%q): %v", tt.pref, err)
+		}
+		var got []string
+		for _, r := range m.Subtitles(p) {
+			got = append(got, r.Name)
+		}
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("Subtitles(%q) = %q, want %q", tt.pref, got, tt.want)
+		}
+	}
+
+	if _, err := parseSubtitlePref("en:hoh"); err == nil {
+		t.Error("parseSubtitlePref accepted unknown kind")
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// ProbeResult is the technical summary printed by the probe subcommand.
+type ProbeResult struct {
+	URL           string  `json:"url"`
+	VideoCodec    string  `json:"video_codec,omitempty"`
+	Width         int     `json:"width,omitempty"`
+	Height        int     `json:"height,omitempty"`
+	FPS           float64 `json:"fps,omitempty"`
+	AudioCodec    string  `json:"audio_codec,omitempty"`
+	AudioChannels int     `json:"audio_channels,omitempty"`
+	Duration      float64 `json:"duration_seconds,omitempty"`
+}
+
+func runProbe(args []string) error {
+	flags := flag.NewFlagSet("probe", flag.ExitOnError)
+	media := addMediaFlags(flags)
+	asJSON := flags.Bool("json", false, "print the result as JSON")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return errors.New(msg(msgProbeUsage))
+	}
+
+	if _, err := loadConfig(); err != nil {
+		return err
+	}
+	master, err := media.options(flags.Arg(0)).resolveRecorded(lastFailurePath())
+	if err != nil {
+		return err
+	}
+
+	res, err := probeStream(master.bestVariant().URL)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(res)
+	}
+	fmt.Println(msg(msgProbeVideo, res.VideoCodec, res.Width, res.Height, res.FPS))
+	fmt.Println(msg(msgProbeAudio, res.AudioCodec, res.AudioChannels))
+	fmt.Println(msg(msgProbeDuration, formatTimestamp(secondsDuration(res.Duration))))
+	return nil
+}
+
+// probeStream runs ffprobe against streamURL.
+func probeStream(streamURL string) (ProbeResult, error) {
+	ffprobe, err := exec.LookPath("ffprobe")
+	if err != nil {
+		return ProbeResult{}, fmt.Errorf("ffprobe is required for probing: %w", err)
+	}
+
+	args := []string{"-v", "error", "-of", "json", "-show_format", "-show_streams"}
+	if h := ffmpegHeaders(vidsrc.cdnHeaders()); h != "" {
+		args = append(args, "-headers", h)
+	}
+	args = append(args, streamURL)
+
+	cmd := exec.Command(ffprobe, args...)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return ProbeResult{}, fmt.Errorf("running ffprobe: %w", err)
+	}
+
+	res, err := parseProbe(out)
+	if err != nil {
+		return res, err
+	}
+	res.URL = streamURL
+	return res, nil
+}
+
+// ffprobeOutput is the part of ffprobe's JSON output that probe reads.
+type ffprobeOutput struct {
+	Streams []struct {
+		CodecType    string `json:"codec_type"`
+		CodecName    string `json:"codec_name"`
+		Width        int    `json:"width"`
+		Height       int    `json:"height"`
+		AvgFrameRate string `json:"avg_frame_rate"`
+		RFrameRate   string `json:"r_frame_rate"`
+		Channels     int    `json:"channels"`
+	} `json:"streams"`
+	Format struct {
+		Duration string `json:"duration"`
+	} `json:"format"`
+}
+
+// parseProbe summarizes ffprobe's JSON output, using the first video and
+// audio streams.
+func parseProbe(data []byte) (ProbeResult, error) {
+	var out ffprobeOutput
+	if err := json.Unmarshal(data, &out); err != nil {
+		return ProbeResult{}, fmt.Errorf("parsing ffprobe output: %w", err)
+	}
+
+	var res ProbeResult
+	for _, s := range out.Streams {
+		switch {
+		case s.CodecType == "video" && res.VideoCodec == "":
+			res.VideoCodec, res.Width, res.Height = s.CodecName, s.Width, s.Height
+			if res.FPS = parseRate(s.AvgFrameRate); res.FPS == 0 {
+				res.FPS = parseRate(s.RFrameRate)
+			}
+		case s.CodecType == "audio" && res.AudioCodec == "":
+			res.AudioCodec, res.AudioChannels = s.CodecName, s.Channels
+		}
+	}
+	res.Duration, _ = strconv.ParseFloat(out.Format.Duration, 64)
+	return res, nil
+}
+
+// parseRate reads an ffprobe frame rate such as "24000/1001", rounded to
+// three decimals; unknown rates ("0/0") are 0.
+func parseRate(s string) float64 {
+	num, den, ok := strings.Cut(s, "/")
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0
+	}
+	if ok {
+		d, err := strconv.ParseFloat(den, 64)
+		if err != nil || d == 0 {
+			return 0
+		}
+		n /= d
+	}
+	return float64(int(n*1000+0.5)) / 1000
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseProbe(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "probe", "hls_1080p.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := parseProbe(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ProbeResult{
+		VideoCodec:    "h264",
+		Width:         1920,
+		Height:        1080,
+		FPS:           23.976,
+		AudioCodec:    "aac",
+		AudioChannels: 6,
+		Duration:      8340.256,
+	}
+	if got != want {
+		t.Errorf("parseProbe = %+v, want %+v", got, want)
+	}
+}
+
+func TestParseRate(t *testing.T) {
+	for in, want := range map[string]float64{"24000/1001": 23.976, "25/1": 25, "0/0": 0, "30": 30, "": 0} {
+		if got := parseRate(in); got != want {
+			t.Errorf("parseRate(%q) = %v, want %v", in, got, want)
+		}
+	}
+}
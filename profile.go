@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// Profile is a named set of picture, audio and subtitle choices from the
+// config, applied together with -profile. Empty fields leave that choice
+// open.
+type Profile struct {
+	MaxHeight     int    `json:"max_height"`     // e.g. 1080
+	MaxBandwidth  int    `json:"max_bandwidth"`  // bits per second, for low-data profiles
+	Codec         string `json:"codec"`          // h264, hevc, av1 or vp9
+	FrameRate     string `json:"frame_rate"`     // as for -fps, e.g. "!24"
+	Audio         string `json:"audio"`          // audio language, e.g. "en"
+	AudioChannels int    `json:"audio_channels"` // preferred channel count, e.g. 2 or 6
+	Subs          string `json:"subs"`           // lang[:kind], as for -subs
+
+	name string // key in the config, for messages
+}
+
+// Selection is what a profile picked from a master playlist. Audio and
+// Subtitles are nil when the variant's own tracks are to be used.
+type Selection struct {
+	Variant   StreamVariant
+	Audio     *Rendition
+	Subtitles *Rendition
+}
+
+// videoCodec names the video codec in v's CODECS attribute, or "" if it
+// has none that is known.
+func (v StreamVariant) videoCodec() string {
+	for _, c := range strings.Split(v.Codecs, ",") {
+		switch tag, _, _ := strings.Cut(strings.TrimSpace(c), "."); tag {
+		case "avc1", "avc3":
+			return "h264"
+		case "hvc1", "hev1", "dvh1", "dvhe":
+			return "hevc"
+		case "av01", "dav1":
+			return "av1"
+		case "vp09":
+			return "vp9"
+		}
+	}
+	return ""
+}
+
+// allows reports whether v fits the profile's picture limits. Variants
+// without the attribute a limit needs are let through.
+func (p Profile) allows(v StreamVariant) bool {
+	if h := v.height(); p.MaxHeight > 0 && h > p.MaxHeight {
+		return false
+	}
+	if bw, err := strconv.Atoi(v.Bandwidth); err == nil && p.MaxBandwidth > 0 && bw > p.MaxBandwidth {
+		return false
+	}
+	if c := v.videoCodec(); p.Codec != "" && c != "" && !strings.EqualFold(c, p.Codec) {
+		return false
+	}
+	return true
+}
+
+// apply keeps the variants of m that fit p, then prefers its frame rate.
+// When none fits, all variants are kept, so a strict profile still plays
+// something.
+func (p Profile) apply(m *MasterPlaylist) {
+	var fit []StreamVariant
+	for _, v := range m.Variants {
+		if p.allows(v) {
+			fit = append(fit, v)
+		}
+	}
+	if len(fit) == 0 {
+		log.Printf("No variant fits profile %q, keeping all of them", p.name)
+		fit = m.Variants
+	}
+	fps, _ := parseFrameRatePref(p.FrameRate) // checked by lookupProfile
+	m.Variants = fps.filter(fit)
+}
+
+// selectTracks picks the best variant of m and the audio and subtitle
+// renditions of its groups that match p.
+func (p Profile) selectTracks(m *MasterPlaylist) (Selection, error) {
+	sel := Selection{Variant: m.bestVariant()}
+
+	if p.Audio != "" || p.AudioChannels > 0 {
+		for _, r := range m.Renditions {
+			if r.Type != "AUDIO" || r.GroupID != sel.Variant.Audio || r.DescribesVideo() || !matchesLanguage(r.Language, p.Audio) {
+				continue
+			}
+			if sel.Audio == nil || p.betterAudio(r, *sel.Audio) {
+				sel.Audio = &r
+			}
+		}
+	}
+
+	if p.Subs != "" {
+		pref, err := parseSubtitlePref(p.Subs)
+		if err != nil {
+			return sel, err
+		}
+		for _, r := range m.Subtitles(pref) {
+			if r.GroupID == sel.Variant.Subtitles && r.URL != "" {
+				sel.Subtitles = &r
+				break
+			}
+		}
+	}
+	return sel, nil
+}
+
+// betterAudio reports whether audio rendition a suits p better than b: its
+// channel count is closer to the preferred one or, failing that, it is the
+// default track.
+func (p Profile) betterAudio(a, b Rendition) bool {
+	if p.AudioChannels > 0 {
+		da, db := channelDistance(a, p.AudioChannels), channelDistance(b, p.AudioChannels)
+		if da != db {
+			return da < db
+		}
+	}
+	return a.Default && !b.Default
+}
+
+func channelDistance(r Rendition, want int) int {
+	n := r.channelCount()
+	if n == 0 {
+		n = 2 // CHANNELS may be left out for stereo
+	}
+	if n > want {
+		return n - want
+	}
+	return want - n
+}
+
+// parseChannels parses an -audio-channels value: a count such as "6", a
+// layout such as "5.1", or "stereo" or "surround".
+func parseChannels(s string) (int, error) {
+	switch s = strings.ToLower(strings.TrimSpace(s)); s {
+	case "":
+		return 0, nil
+	case "stereo":
+		return 2, nil
+	case "surround":
+		return 6, nil
+	}
+	front, lfe, layout := strings.Cut(s, ".")
+	n, err := strconv.Atoi(front)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid channel count %q (want e.g. 2, 5.1 or stereo)", s)
+	}
+	if layout {
+		l, err := strconv.Atoi(lfe)
+		if err != nil || l < 0 {
+			return 0, fmt.Errorf("invalid channel layout %q (want e.g. 5.1)", s)
+		}
+		n += l
+	}
+	return n, nil
+}
+
+// lookupProfile returns the named profile from cfg, checking its subtitle
+// and frame-rate preferences up front.
+func lookupProfile(cfg Config, name string) (*Profile, error) {
+	p, ok := cfg.Profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown profile %q; define it under \"profiles\" in the config", name)
+	}
+	if _, err := parseSubtitlePref(p.Subs); err != nil {
+		return nil, fmt.Errorf("profile %q: %w", name, err)
+	}
+	if _, err := parseFrameRatePref(p.FrameRate); err != nil {
+		return nil, fmt.Errorf("profile %q: %w", name, err)
+	}
+	p.name = name
+	return &p, nil
+}
+
+// trackName describes r for output, "-" meaning none was picked.
+func trackName(r *Rendition) string {
+	if r == nil {
+		return "-"
+	}
+	return r.Name
+}
@@ -0,0 +1,115 @@
+package main
+
+import "testing"
+
+const masterForProfiles = `#EXTM3U
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aud",NAME="English",LANGUAGE="en",DEFAULT=YES,URI="audio/en.m3u8"
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aud",NAME="Español",LANGUAGE="es",URI="audio/es.m3u8"
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aud",NAME="Español (AD)",LANGUAGE="es",CHARACTERISTICS="public.accessibility.describes-video",URI="audio/es-ad.m3u8"
+#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="subs",NAME="English",LANGUAGE="en",URI="subs/en.m3u8"
+#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="subs",NAME="English (SDH)",LANGUAGE="en",CHARACTERISTICS="public.accessibility.transcribes-spoken-dialog",URI="subs/en-sdh.m3u8"
+#EXT-X-STREAM-INF:BANDWIDTH=16000000,RESOLUTION=3840x2160,CODECS="hvc1.2.4.L150,mp4a.40.2",AUDIO="aud",SUBTITLES="subs"
+2160/index.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=5000000,RESOLUTION=1920x1080,FRAME-RATE=23.976,CODECS="avc1.640028,mp4a.40.2",AUDIO="aud",SUBTITLES="subs"
+1080/index.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=1800000,RESOLUTION=1280x720,CODECS="avc1.4d401f,mp4a.40.2",AUDIO="aud",SUBTITLES="subs"
+720/index.m3u8
+`
+
+func TestProfileSelection(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile Profile
+		want    string // resolution | audio | subtitles
+	}{
+		{"none", Profile{}, "3840x2160 | - | -"},
+		{"tv", Profile{MaxHeight: 1080, Codec: "h264", Audio: "es", Subs: "en:sdh"}, "1920x1080@23.976 | Español | English (SDH)"},
+		{"phone", Profile{MaxHeight: 720, MaxBandwidth: 2000000, Audio: "en"}, "1280x720 | English | -"},
+		{"hevc", Profile{Codec: "HEVC"}, "3840x2160 | - | -"},
+		{"impossible", Profile{MaxHeight: 480}, "3840x2160 | - | -"},
+		{"no 24p", Profile{MaxHeight: 1080, FrameRate: "!24"}, "1280x720 | - | -"},
+	}
+	for _, tt := range tests {
+		m, err := parseMasterPlaylist("https://cdn.example.test/pl/master.m3u8", masterForProfiles)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tt.profile.apply(m)
+		sel, err := tt.profile.selectTracks(m)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := sel.Variant.label() + " | " + trackName(sel.Audio) + " | " + trackName(sel.Subtitles); got != tt.want {
+			t.Errorf("%s: selected %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLookupProfile(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Profiles = map[string]Profile{"phone": {MaxHeight: 720}, "bad": {Subs: "en:hoh"}, "badfps": {FrameRate: "fast"}}
+
+	p, err := lookupProfile(cfg, "phone")
+	if err != nil || p.MaxHeight != 720 || p.name != "phone" {
+		t.Errorf("lookupProfile(phone) = %+v, %v", p, err)
+	}
+	if _, err := lookupProfile(cfg, "tv"); err == nil {
+		t.Error("lookupProfile accepted an undefined profile")
+	}
+	if _, err := lookupProfile(cfg, "bad"); err == nil {
+		t.Error("lookupProfile accepted an invalid subtitle preference")
+	}
+	if _, err := lookupProfile(cfg, "badfps"); err == nil {
+		t.Error("lookupProfile accepted an invalid frame rate")
+	}
+}
+
+func TestAudioChannelPreference(t *testing.T) {
+	const master = `#EXTM3U
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aud",NAME="English 5.1",LANGUAGE="en",DEFAULT=YES,CHANNELS="6",URI="audio/en-51.m3u8"
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aud",NAME="English Stereo",LANGUAGE="en",CHANNELS="2",URI="audio/en-20.m3u8"
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aud",NAME="English Atmos",LANGUAGE="en",CHANNELS="16/JOC",URI="audio/en-atmos.m3u8"
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aud",NAME="Deutsch Stereo",LANGUAGE="de",CHANNELS="2",URI="audio/de-20.m3u8"
+#EXT-X-STREAM-INF:BANDWIDTH=5000000,RESOLUTION=1920x1080,AUDIO="aud"
+1080/index.m3u8
+`
+	m, err := parseMasterPlaylist("https://cdn.example.test/pl/master.m3u8", master)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		profile Profile
+		want    string
+	}{
+		{Profile{AudioChannels: 2, Audio: "en"}, "English Stereo"},
+		{Profile{AudioChannels: 6, Audio: "en"}, "English 5.1"},
+		{Profile{AudioChannels: 8, Audio: "en"}, "English 5.1"},
+		{Profile{AudioChannels: 16}, "English Atmos"},
+		{Profile{AudioChannels: 2, Audio: "de"}, "Deutsch Stereo"},
+		{Profile{AudioChannels: 6, Audio: "de"}, "Deutsch Stereo"},
+		{Profile{Audio: "en"}, "English 5.1"},
+	}
+	for _, tt := range tests {
+		sel, err := tt.profile.selectTracks(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := trackName(sel.Audio); got != tt.want {
+			t.Errorf("%+v picked %q, want %q", tt.profile, got, tt.want)
+		}
+	}
+}
+
+func TestParseChannels(t *testing.T) {
+	tests := map[string]int{"": 0, "2": 2, "2.0": 2, "5.1": 6, "7.1": 8, "Stereo": 2, "surround": 6}
+	for in, want := range tests {
+		if got, err := parseChannels(in); err != nil || got != want {
+			t.Errorf("parseChannels(%q) = %d, %v; want %d", in, got, err, want)
+		}
+	}
+	for _, bad := range []string{"mono-ish", "0", "5.x", "-2"} {
+		if _, err := parseChannels(bad); err == nil {
+			t.Errorf("parseChannels(%q) succeeded, want error", bad)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// minBandwidth is the lowest plausible bandwidth, in bits per second, for a
+// genuine encode at each height. Cam rips and re-encodes of them are often
+// labeled 1080p while carrying far less data than a real 1080p stream.
+var minBandwidth = []struct {
+	height    int
+	bandwidth int
+}{
+	{2160, 4000000},
+	{1080, 1500000},
+	{720, 800000},
+}
+
+// height returns the vertical resolution from a RESOLUTION like "1920x1080".
+func (v StreamVariant) height() int {
+	_, h, ok := strings.Cut(v.Resolution, "x")
+	if !ok {
+		return 0
+	}
+	n, _ := strconv.Atoi(h)
+	return n
+}
+
+// lowBitrate reports whether v's bandwidth is suspiciously low for its
+// resolution, which suggests a cam rip or a mislabeled stream.
+func (v StreamVariant) lowBitrate() bool {
+	bw, err := strconv.Atoi(v.Bandwidth)
+	if err != nil || bw <= 0 {
+		return false
+	}
+	h := v.height()
+	for _, m := range minBandwidth {
+		if h >= m.height {
+			return bw < m.bandwidth
+		}
+	}
+	return false
+}
+
+// Dynamic ranges of a variant, as reported by dynamicRange.
+const (
+	RangeSDR         = "SDR"
+	RangeHDR10       = "HDR10"
+	RangeHLG         = "HLG"
+	RangeDolbyVision = "Dolby Vision"
+)
+
+// dynamicRange classifies v from its Dolby Vision codec tags and its
+// VIDEO-RANGE, which is PQ for HDR10 and HLG for broadcast HDR. Variants
+// without either are taken to be SDR.
+func (v StreamVariant) dynamicRange() string {
+	for _, c := range strings.Split(v.Codecs, ",") {
+		switch tag, _, _ := strings.Cut(strings.TrimSpace(c), "."); tag {
+		case "dvh1", "dvhe", "dav1", "dva1", "dvav":
+			return RangeDolbyVision
+		}
+	}
+	switch strings.ToUpper(v.VideoRange) {
+	case "PQ":
+		return RangeHDR10
+	case "HLG":
+		return RangeHLG
+	}
+	return RangeSDR
+}
+
+// hdr reports whether v needs an HDR display or tone mapping.
+func (v StreamVariant) hdr() bool {
+	return v.dynamicRange() != RangeSDR
+}
+
+// withoutHDR returns the SDR variants, or all of them when every variant
+// is HDR, so -no-hdr still plays something.
+func withoutHDR(variants []StreamVariant) []StreamVariant {
+	var sdr []StreamVariant
+	for _, v := range variants {
+		if !v.hdr() {
+			sdr = append(sdr, v)
+		}
+	}
+	if len(sdr) == 0 {
+		log.Println("Every variant is HDR, keeping all of them")
+		return variants
+	}
+	return sdr
+}
+
+// frameRate returns v's FRAME-RATE, or 0 if the playlist does not give one.
+func (v StreamVariant) frameRate() float64 {
+	r, err := strconv.ParseFloat(v.FrameRate, 64)
+	if err != nil || r <= 0 {
+		return 0
+	}
+	return r
+}
+
+// label is v's resolution with its frame rate, e.g. "1920x1080@23.976".
+func (v StreamVariant) label() string {
+	if v.frameRate() == 0 {
+		return v.Resolution
+	}
+	return v.Resolution + "@" + v.FrameRate
+}
+
+// frameRatePref selects variants by frame rate. Rates are compared rounded
+// to whole frames, so 24 also matches 23.976 and 60 matches 59.94.
+type frameRatePref struct {
+	rates   []float64
+	exclude bool // the rates are to be avoided rather than kept
+}
+
+// parseFrameRatePref parses a -fps value: a comma-separated list of rates
+// to keep, e.g. "50,60", or one to avoid when prefixed with "!", e.g. "!24".
+func parseFrameRatePref(s string) (frameRatePref, error) {
+	var p frameRatePref
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return p, nil
+	}
+	if rest, ok := strings.CutPrefix(s, "!"); ok {
+		p.exclude, s = true, rest
+	}
+	for _, f := range strings.Split(s, ",") {
+		r, err := strconv.ParseFloat(strings.TrimSpace(f), 64)
+		if err != nil || r <= 0 {
+			return p, fmt.Errorf("invalid frame rate %q", strings.TrimSpace(f))
+		}
+		p.rates = append(p.rates, math.Round(r))
+	}
+	return p, nil
+}
+
+// allows reports whether v fits p. A variant whose frame rate is unknown
+// is never among the rates to keep, but is not avoided either.
+func (p frameRatePref) allows(v StreamVariant) bool {
+	r := v.frameRate()
+	listed := false
+	for _, want := range p.rates {
+		if r > 0 && math.Round(r) == want {
+			listed = true
+		}
+	}
+	return listed != p.exclude
+}
+
+// filter returns the variants that fit p, or all of them when none does,
+// so a display preference never leaves nothing to play.
+func (p frameRatePref) filter(variants []StreamVariant) []StreamVariant {
+	if len(p.rates) == 0 {
+		return variants
+	}
+	var fit []StreamVariant
+	for _, v := range variants {
+		if p.allows(v) {
+			fit = append(fit, v)
+		}
+	}
+	if len(fit) == 0 {
+		log.Println("No variant has the preferred frame rate, keeping all of them")
+		return variants
+	}
+	return fit
+}
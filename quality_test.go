@@ -0,0 +1,100 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLowBitrate(t *testing.T) {
+	tests := []struct {
+		res, bw string
+		want    bool
+	}{
+		{"1920x1080", "5000000", false},
+		{"1920x1080", "900000", true},
+		{"1280x720", "2800000", false},
+		{"1280x720", "600000", true},
+		{"3840x2160", "3000000", true},
+		{"640x360", "300000", false},
+		{"", "100", false},
+		{"1920x1080", "", false},
+	}
+	for _, tt := range tests {
+		v := StreamVariant{Resolution: tt.res, Bandwidth: tt.bw}
+		if got := v.lowBitrate(); got != tt.want {
+			t.Errorf("lowBitrate(%s @ %s) = %v, want %v", tt.res, tt.bw, got, tt.want)
+		}
+	}
+}
+
+const masterWithHDR = `#EXTM3U
+#EXT-X-STREAM-INF:BANDWIDTH=18000000,RESOLUTION=3840x2160,CODECS="dvh1.05.06,ec-3",VIDEO-RANGE=PQ
+dv/index.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=16000000,RESOLUTION=3840x2160,CODECS="hvc1.2.4.L150,mp4a.40.2",VIDEO-RANGE=PQ
+hdr10/index.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=12000000,RESOLUTION=3840x2160,CODECS="hvc1.2.4.L150",VIDEO-RANGE=HLG
+hlg/index.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=5000000,RESOLUTION=1920x1080,CODECS="avc1.640028,mp4a.40.2",VIDEO-RANGE=SDR
+1080/index.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=2800000,RESOLUTION=1280x720
+720/index.m3u8
+`
+
+func TestDynamicRange(t *testing.T) {
+	m, err := parseMasterPlaylist("https://cdn.example.test/pl/master.m3u8", masterWithHDR)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{RangeDolbyVision, RangeHDR10, RangeHLG, RangeSDR, RangeSDR}
+	for i, v := range m.Variants {
+		if got := v.dynamicRange(); got != want[i] {
+			t.Errorf("variant %d (%s, %s) = %s, want %s", i, v.Codecs, v.VideoRange, got, want[i])
+		}
+	}
+
+	sdr := withoutHDR(m.Variants)
+	if len(sdr) != 2 || sdr[0].Resolution != "1920x1080" {
+		t.Errorf("withoutHDR = %+v, want the 1080p and 720p variants", sdr)
+	}
+	if all := withoutHDR(m.Variants[:3]); len(all) != 3 {
+		t.Errorf("withoutHDR of only HDR variants = %d variants, want all 3 kept", len(all))
+	}
+}
+
+func TestFrameRatePref(t *testing.T) {
+	variants := []StreamVariant{
+		{Resolution: "1920x1080", FrameRate: "23.976"},
+		{Resolution: "1920x1080", FrameRate: "50.000"},
+		{Resolution: "1280x720", FrameRate: "59.94"},
+		{Resolution: "640x360"},
+	}
+	tests := []struct {
+		pref string
+		want string // labels of the kept variants
+	}{
+		{"", "1920x1080@23.976 1920x1080@50.000 1280x720@59.94 640x360"},
+		{"24", "1920x1080@23.976"},
+		{"50,60", "1920x1080@50.000 1280x720@59.94"},
+		{"!24", "1920x1080@50.000 1280x720@59.94 640x360"},
+		{"30", "1920x1080@23.976 1920x1080@50.000 1280x720@59.94 640x360"}, // none fits
+	}
+	for _, tt := range tests {
+		p, err := parseFrameRatePref(tt.pref)
+		if err != nil {
+			t.Fatalf("parseFrameRatePref(%q): %v", tt.pref, err)
+		}
+		var got []string
+		for _, v := range p.filter(variants) {
+			got = append(got, v.label())
+		}
+		if strings.Join(got, " ") != tt.want {
+			t.Errorf("-fps %q kept %q, want %q", tt.pref, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"fast", "!", "24,", "-24"} {
+		if _, err := parseFrameRatePref(bad); err == nil {
+			t.Errorf("parseFrameRatePref(%q) succeeded, want error", bad)
+		}
+	}
+}
@@ -0,0 +1,610 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Replay fixtures live in testdata/replay/<name>.json. Running the tests with
+// -record hits the live sites instead and rewrites the fixtures, e.g.
+//
+//	go test -run TestReplay -record
+//
+// Recorded responses are sanitized before they are written: cookies and
+// per-request headers are dropped, and session tokens and signed CDN URLs are
+// replaced by placeholders (see scrubFixture), so fixtures are stable and
+// safe to commit.
+var record = flag.Bool("record", false, "record live provider responses into testdata/replay")
+
+// interaction is one recorded request/response pair.
+type interaction struct {
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body"`
+}
+
+type fixture struct {
+	Interactions []interaction `json:"interactions"`
+}
+
+// sanitizedHeaders is the allowlist of response headers kept in fixtures.
+var sanitizedHeaders = []string{"Content-Type", "ETag", "Last-Modified"}
+
+func fixturePath(name string) string {
+	return filepath.Join("testdata", "replay", name+".json")
+}
+
+// replayURLHeader carries the original request URL to the replay server.
+const replayURLHeader = "X-Replay-URL"
+
+// useReplayClient points the shared client at the named fixture for the
+// duration of the test. Every host is routed to a single httptest server that
+// answers from the fixture; requests that were not recorded fail the test.
+func useReplayClient(t *testing.T, name string) {
+	t.Helper()
+
+	if *record {
+		useRecordingClient(t, name)
+		return
+	}
+
+	data, err := os.ReadFile(fixturePath(name))
+	if err != nil {
+		t.Fatalf("reading fixture: %v", err)
+	}
+	var fx fixture
+	if err := json.Unmarshal(data, &fx); err != nil {
+		t.Fatalf("parsing fixture %s: %v", name, err)
+	}
+	replayFixture(t, name, fx)
+}
+
+// replayFixture points the shared client at fx, named name in failures.
+func replayFixture(t *testing.T, name string, fx fixture) {
+	t.Helper()
+
+	// A URL recorded more than once is answered in order, repeating the
+	// last response once the sequence runs out.
+	var mu sync.Mutex
+	recorded := map[string][]interaction{}
+	for _, in := range fx.Interactions {
+		key := in.Method + " " + in.URL
+		recorded[key] = append(recorded[key], in)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Method + " " + r.Header.Get(replayURLHeader)
+		mu.Lock()
+		seq := recorded[key]
+		if len(seq) > 1 {
+			recorded[key] = seq[1:]
+		}
+		mu.Unlock()
+		if len(seq) == 0 {
+			t.Errorf("unrecorded request in fixture %s: %s", name, key)
+			http.Error(w, "not recorded", http.StatusNotImplemented)
+			return
+		}
+		in := seq[0]
+		for k, v := range in.Headers {
+			w.Header().Set(k, v)
+		}
+		w.WriteHeader(in.Status)
+		io.WriteString(w, in.Body)
+	}))
+	t.Cleanup(srv.Close)
+
+	swapClient(t, &http.Client{Transport: &replayTransport{target: srv.URL}})
+}
+
+// replayTransport rewrites every request to the replay server.
+type replayTransport struct {
+	target string
+}
+
+func (rt *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	out := req.Clone(req.Context())
+	out.Header.Set(replayURLHeader, req.URL.String())
+	out.URL.Scheme = "http"
+	out.URL.Host = rt.target[len("http://"):]
+	out.Host = ""
+	return http.DefaultTransport.RoundTrip(out)
+}
+
+// useRecordingClient sends requests to the live sites and writes what it saw
+// to the named fixture when the test finishes.
+func useRecordingClient(t *testing.T, name string) {
+	t.Helper()
+	rec := &recordingTransport{next: http.DefaultTransport}
+	swapClient(t, &http.Client{Transport: rec})
+
+	t.Cleanup(func() {
+		data, err := json.MarshalIndent(scrubFixture(fixture{Interactions: rec.interactions}), "", "\t")
+		if err != nil {
+			t.Fatalf("encoding fixture: %v", err)
+		}
+		if err := os.WriteFile(fixturePath(name), append(data, '\n'), 0644); err != nil {
+			t.Fatalf("writing fixture: %v", err)
+		}
+		t.Logf("recorded %d interactions into %s", len(rec.interactions), fixturePath(name))
+	})
+}
+
+type recordingTransport struct {
+	next         http.RoundTripper
+	mu           sync.Mutex
+	interactions []interaction
+}
+
+func (rt *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := rt.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	in := interaction{
+		Method:  req.Method,
+		URL:     req.URL.String(),
+		Status:  resp.StatusCode,
+		Headers: map[string]string{},
+		Body:    string(body),
+	}
+	for _, h := range sanitizedHeaders {
+		if v := resp.Header.Get(h); v != "" {
+			in.Headers[h] = v
+		}
+	}
+
+	rt.mu.Lock()
+	rt.interactions = append(rt.interactions, in)
+	rt.mu.Unlock()
+	return resp, nil
+}
+
+// fixtureEncoders re-obfuscate a hidden-div payload once its URL has been
+// scrubbed, by the name of the scheme that decoded it.
+var fixtureEncoders = map[string]func(string) string{
+	"reverse-stride": obfuscate,
+	"caesar3": func(plain string) string {
+		return strings.Map(func(r rune) rune {
+			switch {
+			case r >= 'a' && r <= 'z':
+				return 'a' + (r-'a'+23)%26
+			case r >= 'A' && r <= 'Z':
+				return 'A' + (r-'A'+23)%26
+			}
+			return r
+		}, plain)
+	},
+}
+
+var (
+	// providerTokenRE matches the per-session hashes in RCP and ProRCP paths.
+	providerTokenRE = regexp.MustCompile(`/(rcp|prorcp)/([A-Za-z0-9+/=_-]+)`)
+	// playlistURIRE matches the URIs of a playlist: its non-tag lines and URI
+	// attributes.
+	playlistURIRE = regexp.MustCompile(`(?m)^[^#\s][^\r\n]*|URI="[^"]*"`)
+)
+
+// scrubFixture replaces what identifies a live session in fx with stable
+// placeholders: RCP and ProRCP hashes, query strings outside the embed page,
+// and the host and directory of every playlist. Each value is replaced the
+// same way wherever it appears, in URLs, in bodies and inside hidden-div
+// payloads, so the scrubbed fixture still replays.
+func scrubFixture(fx fixture) fixture {
+	pairs := map[string]string{}
+	add := func(old, format string) {
+		if _, ok := pairs[old]; !ok && old != "" {
+			pairs[old] = fmt.Sprintf(format, len(pairs)+1)
+		}
+	}
+
+	embedHost := ""
+	if u, err := url.Parse(vidsrc.EmbedBase); err == nil {
+		embedHost = u.Host
+	}
+	for _, in := range fx.Interactions {
+		for _, m := range providerTokenRE.FindAllStringSubmatch(in.URL+"\n"+in.Body, -1) {
+			add(m[2], m[1]+"-token-%d")
+		}
+		u, err := url.Parse(in.URL)
+		if err != nil {
+			continue
+		}
+		if u.Host != embedHost {
+			add("?"+u.RawQuery, "?token=scrubbed-%d")
+		}
+		if strings.HasPrefix(in.Body, "#EXTM3U") {
+			// Media playlists usually sit below their master's directory,
+			// which keeps their relative URIs resolving once it is replaced.
+			dir := u.Host + path.Dir(u.Path)
+			below := false
+			for old := range pairs {
+				below = below || strings.HasPrefix(dir, old+"/")
+			}
+			if !below {
+				add(dir, "cdn.example.test/pl/%d")
+			}
+			for _, uri := range playlistURIRE.FindAllString(in.Body, -1) {
+				if _, query, ok := strings.Cut(strings.Trim(strings.TrimPrefix(uri, "URI="), `"`), "?"); ok {
+					add("?"+query, "?token=scrubbed-%d")
+				}
+			}
+		}
+	}
+	delete(pairs, "?")
+
+	// Longer values first, so a token is not half-replaced by a shorter one
+	// it contains.
+	olds := make([]string, 0, len(pairs))
+	for old := range pairs {
+		olds = append(olds, old)
+	}
+	sort.Slice(olds, func(i, j int) bool { return len(olds[i]) > len(olds[j]) })
+	var args []string
+	for _, old := range olds {
+		args = append(args, old, pairs[old])
+	}
+	r := strings.NewReplacer(args...)
+
+	out := fixture{Interactions: make([]interaction, len(fx.Interactions))}
+	for i, in := range fx.Interactions {
+		in.URL = r.Replace(in.URL)
+		in.Body = r.Replace(scrubPayloads(in.Body, r))
+		out.Interactions[i] = in
+	}
+	return out
+}
+
+// scrubPayloads re-obfuscates the hidden-div payloads of page whose decoded
+// text r changes.
+func scrubPayloads(page string, r *strings.Replacer) string {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+	if err != nil {
+		return page
+	}
+	for _, payload := range hiddenDivs(doc) {
+		s, decoded, err := detectScheme(payload)
+		encode, known := fixtureEncoders[s.Name]
+		if err != nil || !known {
+			continue
+		}
+		if scrubbed := r.Replace(decoded); scrubbed != decoded {
+			page = strings.ReplaceAll(page, payload, encode(scrubbed))
+		}
+	}
+	return page
+}
+
+// swapClient replaces the shared client and cache for the test, restoring
+// them afterwards.
+func swapClient(t *testing.T, c *http.Client) {
+	oldClient, oldCache := client, cache
+	client, cache = c, newMemoryCache(maxMemoryCacheEntries)
+	t.Cleanup(func() { client, cache = oldClient, oldCache })
+}
+
+func TestScrubFixture(t *testing.T) {
+	if *record {
+		t.Skip("runs on a hand-written fixture")
+	}
+	master := "https://tmstr.live-cdn.test/pl/SIGNEDPATH/master.m3u8?e=1700000000&sig=SECRETSIG"
+	live := fixture{Interactions: []interaction{
+		{Method: "GET", URL: "https://vidsrc-embed.ru/embed/movie?imdb=tt0137523", Status: 200,
+			Body: `<iframe id="player_iframe" src="//cloudnestra.com/rcp/SESSIONHASH1"></iframe>`},
+		{Method: "GET", URL: "https://cloudnestra.com/rcp/SESSIONHASH1", Status: 200,
+			Body: `<script>$('<iframe>', {src: '/prorcp/SESSIONHASH2'})</script>`},
+		{Method: "GET", URL: "https://cloudnestra.com/prorcp/SESSIONHASH2", Status: 200,
+			Body: `<div style="display:none;">` + obfuscate(master) + `</div>`},
+		{Method: "GET", URL: master, Status: 200,
+			Body: "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=5000000,RESOLUTION=1920x1080\n1080/index.m3u8?sig=SECRETVARIANT\n"},
+	}}
+
+	scrubbed := scrubFixture(live)
+	data, err := json.Marshal(scrubbed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"SESSIONHASH", "SIGNEDPATH", "SECRETSIG", "SECRETVARIANT", "live-cdn"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("scrubbed fixture still contains %q:\n%s", secret, data)
+		}
+	}
+	if scrubbed.Interactions[0].URL != live.Interactions[0].URL {
+		t.Errorf("embed URL = %q, want it kept", scrubbed.Interactions[0].URL)
+	}
+
+	// The scrubbed fixture still resolves, to the placeholder hosts.
+	replayFixture(t, "scrubbed", scrubbed)
+	m, err := ResolveOptions{IMDBID: "tt0137523", Type: Movie}.ResolveMaster()
+	if err != nil {
+		t.Fatalf("ResolveMaster on the scrubbed fixture: %v", err)
+	}
+	if len(m.Variants) != 1 || !strings.HasPrefix(m.Variants[0].URL, "https://cdn.example.test/pl/") || strings.Contains(m.Variants[0].URL, "sig=") {
+		t.Errorf("variants = %+v, want the scrubbed CDN URL", m.Variants)
+	}
+}
+
+func TestReplayResolveMovie(t *testing.T) {
+	useReplayClient(t, "movie_tt0137523")
+
+	opts := ResolveOptions{IMDBID: "tt0137523", Type: Movie}
+	variants, err := opts.ResolveStreams()
+	if err != nil {
+		t.Fatalf("ResolveStreams: %v", err)
+	}
+	if *record {
+		return
+	}
+
+	want := []StreamVariant{
+		{Resolution: "1920x1080", Bandwidth: "5000000", URL: "https://cdn.example.test/pl/1080/index.m3u8"},
+		{Resolution: "1280x720", Bandwidth: "2800000", URL: "https://cdn.example.test/pl/720/index.m3u8"},
+	}
+	if len(variants) != len(want) {
+		t.Fatalf("got %d variants, want %d: %+v", len(variants), len(want), variants)
+	}
+	for i := range want {
+		if variants[i] != want[i] {
+			t.Errorf("variant %d = %+v, want %+v", i, variants[i], want[i])
+		}
+	}
+}
+
+func TestReplayEvents(t *testing.T) {
+	if *record {
+		t.Skip("covered by TestReplayResolveMovie")
+	}
+	useReplayClient(t, "movie_tt0137523")
+
+	var got []string
+	opts := ResolveOptions{IMDBID: "tt0137523", Type: Movie, OnEvent: func(e Event) {
+		got = append(got, e.Step+" "+e.Kind.String())
+		if e.Kind == StepSucceeded && e.Artifact == "" {
+			t.Errorf("step %s succeeded without an artifact", e.Step)
+		}
+	}}
+	if _, err := opts.ResolveMaster(); err != nil {
+		t.Fatalf("ResolveMaster: %v", err)
+	}
+
+	want := []string{
+		"embed started", "embed succeeded",
+		"rcp started", "rcp succeeded",
+		"decode started", "decode succeeded",
+		"playlist started", "playlist succeeded",
+	}
+	if strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Errorf("events = %q, want %q", got, want)
+	}
+}
+
+func TestReplayEventsOnFailure(t *testing.T) {
+	if *record {
+		t.Skip("failure fixture is hand-written")
+	}
+	useReplayClient(t, "movie_no_hidden_div")
+
+	var last Event
+	opts := ResolveOptions{IMDBID: "tt0000001", Type: Movie, OnEvent: func(e Event) { last = e }}
+	if _, err := opts.ResolveVariants(); err == nil {
+		t.Fatal("ResolveVariants succeeded, want error")
+	}
+	if last.Kind != StepFailed || last.Step != StepDecode || last.Err == nil {
+		t.Errorf("last event = %+v, want decode failed", last)
+	}
+}
+
+func TestReplayResolveTV(t *testing.T) {
+	useReplayClient(t, "tv_tt0903747_s1e1")
+
+	opts := ResolveOptions{IMDBID: "tt0903747", Type: TV, Season: 1, Episode: 1}
+	url, err := opts.ResolveVariants()
+	if err != nil {
+		t.Fatalf("ResolveVariants: %v", err)
+	}
+	if want := "https://cdn.example.test/tv/master.m3u8"; !*record && url != want {
+		t.Errorf("ResolveVariants = %q, want %q", url, want)
+	}
+}
+
+func TestReplayMissingHiddenDiv(t *testing.T) {
+	if *record {
+		t.Skip("failure fixture is hand-written")
+	}
+	useReplayClient(t, "movie_no_hidden_div")
+
+	opts := ResolveOptions{IMDBID: "tt0000001", Type: Movie}
+	if _, err := opts.ResolveVariants(); err == nil {
+		t.Fatal("ResolveVariants succeeded, want error for page without hidden div")
+	}
+}
+
+func TestReplayReresolveOnExpiredPlaylist(t *testing.T) {
+	if *record {
+		t.Skip("failure fixture is hand-written")
+	}
+	useReplayClient(t, "movie_expired_master")
+
+	opts := ResolveOptions{IMDBID: "tt0137523", Type: Movie}
+	variants, err := opts.ResolveStreams()
+	if err != nil {
+		t.Fatalf("ResolveStreams: %v", err)
+	}
+	if len(variants) != 2 || variants[0].URL != "https://cdn.example.test/fresh/1080/index.m3u8" {
+		t.Errorf("got variants %+v, want the ones from the re-resolved playlist", variants)
+	}
+}
+
+func TestReplayEventsOnReresolve(t *testing.T) {
+	if *record {
+		t.Skip("failure fixture is hand-written")
+	}
+	useReplayClient(t, "movie_expired_master")
+
+	var got []string
+	opts := ResolveOptions{IMDBID: "tt0137523", Type: Movie, OnEvent: func(e Event) {
+		got = append(got, e.Step+" "+e.Kind.String())
+	}}
+	if _, err := opts.ResolveMaster(); err != nil {
+		t.Fatalf("ResolveMaster: %v", err)
+	}
+
+	want := []string{
+		"embed started", "embed succeeded",
+		"rcp started", "rcp succeeded",
+		"decode started", "decode succeeded",
+		"playlist started", "playlist failed",
+		"rcp started", "rcp succeeded",
+		"decode started", "decode succeeded",
+		"playlist started", "playlist succeeded",
+	}
+	if strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Errorf("events = %q, want %q", got, want)
+	}
+}
+
+func TestReplayEventsOnFailedReresolve(t *testing.T) {
+	if *record {
+		t.Skip("failure fixture is hand-written")
+	}
+	useReplayClient(t, "movie_reresolve_fails")
+
+	var got []string
+	opts := ResolveOptions{IMDBID: "tt0137523", Type: Movie, OnEvent: func(e Event) {
+		got = append(got, e.Step+" "+e.Kind.String())
+	}}
+	if _, err := opts.ResolveMaster(); err == nil {
+		t.Fatal("ResolveMaster succeeded, want the second pass's decode failure")
+	}
+
+	// The second pass fails in decode; the playlist step is not reported
+	// again, since it never started.
+	want := []string{
+		"embed started", "embed succeeded",
+		"rcp started", "rcp succeeded",
+		"decode started", "decode succeeded",
+		"playlist started", "playlist failed",
+		"rcp started", "rcp succeeded",
+		"decode started", "decode failed",
+	}
+	if strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Errorf("events = %q, want %q", got, want)
+	}
+}
+
+func TestReplayTitleNotFound(t *testing.T) {
+	if *record {
+		t.Skip("failure fixtures are hand-written")
+	}
+	tests := []struct {
+		fixture string
+		episode int
+		missing bool
+	}{
+		{"tv_missing_episode", 99, true}, // the embed page is a 404
+		{"tv_rcp_not_found", 1, false},   // the episode exists, its RCP page is gone
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			useReplayClient(t, tt.fixture)
+			opts := ResolveOptions{IMDBID: "tt0903747", Type: TV, Season: 1, Episode: tt.episode}
+			_, err := opts.ResolveMaster()
+			if err == nil {
+				t.Fatal("ResolveMaster succeeded, want the fixture's 404")
+			}
+			if got := errors.Is(err, errTitleNotFound); got != tt.missing {
+				t.Errorf("errors.Is(%v, errTitleNotFound) = %v, want %v", err, got, tt.missing)
+			}
+		})
+	}
+}
+
+func TestReplayFallsBackToNextServer(t *testing.T) {
+	if *record {
+		t.Skip("failure fixture is hand-written")
+	}
+	useReplayClient(t, "movie_two_servers")
+
+	opts := ResolveOptions{IMDBID: "tt0000002", Type: Movie}
+	master, err := opts.ResolveMaster()
+	if err != nil {
+		t.Fatalf("ResolveMaster: %v", err)
+	}
+	if want := "https://cdn.example.test/b/master.m3u8"; master.URL != want {
+		t.Errorf("master URL = %q, want %q from the second server", master.URL, want)
+	}
+
+	opts.Server = 1
+	if _, err := opts.ResolveVariants(); err == nil {
+		t.Error("ResolveVariants with -server 1 succeeded, want the dead first server's error")
+	}
+	opts.Server = 2
+	if url, err := opts.ResolveVariants(); err != nil || url != master.URL {
+		t.Errorf("ResolveVariants with -server 2 = %q, %v; want %q", url, err, master.URL)
+	}
+	opts.Server = 3
+	if _, err := opts.ResolveVariants(); err == nil || !strings.Contains(err.Error(), "lists 2") {
+		t.Errorf("ResolveVariants with -server 3 error = %v, want server not found", err)
+	}
+}
+
+func TestReplayArtifacts(t *testing.T) {
+	if *record {
+		t.Skip("failure fixture is hand-written")
+	}
+	useReplayClient(t, "movie_two_servers")
+
+	opts := ResolveOptions{IMDBID: "tt0000002", Type: Movie}
+	a, err := opts.ResolveArtifacts()
+	if err != nil {
+		t.Fatalf("ResolveArtifacts: %v", err)
+	}
+	want := Artifacts{
+		EmbedURL:  "https://vidsrc-embed.ru/embed/movie?imdb=tt0000002",
+		Server:    "2Embed",
+		RCPURL:    "https://cloudnestra.com/rcp/c2VydmVyYjpyY3A",
+		ProRCPURL: "https://cloudnestra.com/prorcp/c2VydmVyYjpwcm9yY3A",
+		Decoded:   "https://cdn.example.test/b/master.m3u8",
+		MasterURL: "https://cdn.example.test/b/master.m3u8",
+		Master:    a.Master,
+	}
+	if *a != want || a.Master == nil {
+		t.Errorf("ResolveArtifacts = %+v, want %+v", *a, want)
+	}
+
+	// A failed resolution still reports how far it got.
+	opts.Server = 1
+	a, err = opts.ResolveArtifacts()
+	if err == nil {
+		t.Fatal("ResolveArtifacts with the dead server succeeded")
+	}
+	if a.ProRCPURL != "https://cloudnestra.com/prorcp/c2VydmVyYTpwcm9yY3A" || a.Decoded != "" || a.Master != nil {
+		t.Errorf("artifacts of the failed resolution = %+v", *a)
+	}
+}
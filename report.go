@@ -0,0 +1,297 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// Report archives hold build information, the config with secrets redacted,
+// the extraction rules in use, the environment checks and, when there is
+// one, the step log of the last failed resolution.
+const (
+	reportVersionName = "version.txt"
+	reportConfigName  = "config.json"
+	reportRulesName   = "rules-version.txt"
+	reportDoctorName  = "doctor.txt"
+	reportFailureName = "last-failure.json"
+)
+
+// redacted replaces secret values in the report's config.
+const redacted = "REDACTED"
+
+func runReport(args []string) error {
+	flags := flag.NewFlagSet("report", flag.ExitOnError)
+	out := flags.String("o", "film-cli-report-"+time.Now().Format("20060102")+".tar.gz", "archive to write")
+	flags.Parse(args)
+	return createReport(*out, defaultConfigPath(), lastFailurePath())
+}
+
+// lastFailurePath is where the last failed resolution is recorded. The dot
+// keeps it out of backups and away from disk cache entries.
+func lastFailurePath() string {
+	return filepath.Join(defaultCacheDir(), ".last-failure.json")
+}
+
+// failureRecord is the step log of a resolution that failed, with what its
+// steps produced before it stopped.
+type failureRecord struct {
+	Time    time.Time    `json:"time"`
+	IMDBID  string       `json:"imdb_id"`
+	Type    MediaType    `json:"type"`
+	Season  int          `json:"season,omitempty"`
+	Episode int          `json:"episode,omitempty"`
+	Server  int          `json:"server,omitempty"` // as passed with -server
+	Steps   []stepRecord `json:"steps"`
+
+	EmbedURL   string `json:"embed_url,omitempty"`
+	ServerName string `json:"server_name,omitempty"`
+	RCPURL     string `json:"rcp_url,omitempty"`
+	ProRCPURL  string `json:"prorcp_url,omitempty"`
+	Decoded    string `json:"decoded,omitempty"`
+	MasterURL  string `json:"master_url,omitempty"`
+
+	Error string `json:"error"`
+}
+
+// stepRecord is one Event in a failureRecord.
+type stepRecord struct {
+	Step     string `json:"step"`
+	Kind     string `json:"kind"`
+	Artifact string `json:"artifact,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// resolveRecorded is ResolveMaster for the CLI: when resolution fails, its
+// step log is written to path for film-cli report, and when it succeeds an
+// earlier record is removed so reports do not carry a stale failure. The
+// outcome is shared with the status endpoint if the user opted in.
+func (o ResolveOptions) resolveRecorded(path string) (*MasterPlaylist, error) {
+	var steps []stepRecord
+	onEvent := o.OnEvent
+	o.OnEvent = func(e Event) {
+		r := stepRecord{Step: e.Step, Kind: e.Kind.String(), Artifact: e.Artifact}
+		if e.Err != nil {
+			r.Error = e.Err.Error()
+		}
+		steps = append(steps, r)
+		if onEvent != nil {
+			onEvent(e)
+		}
+	}
+
+	a, err := o.ResolveArtifacts()
+	statusSharing.shareStatus(err == nil)
+	if err == nil {
+		if rerr := os.Remove(path); rerr != nil && !errors.Is(rerr, fs.ErrNotExist) {
+			log.Printf("Failed to remove the last failure record: %v", rerr)
+		}
+		return a.Master, nil
+	}
+	rec := failureRecord{
+		Time:       time.Now().UTC(),
+		IMDBID:     o.IMDBID,
+		Type:       o.Type,
+		Season:     o.Season,
+		Episode:    o.Episode,
+		Server:     o.Server,
+		Steps:      steps,
+		EmbedURL:   a.EmbedURL,
+		ServerName: a.Server,
+		RCPURL:     a.RCPURL,
+		ProRCPURL:  a.ProRCPURL,
+		Decoded:    a.Decoded,
+		MasterURL:  a.MasterURL,
+		Error:      err.Error(),
+	}
+	if werr := writeFailure(path, rec); werr != nil {
+		log.Printf("Failed to record the failed resolution: %v", werr)
+	}
+	return nil, err
+}
+
+func writeFailure(path string, rec failureRecord) error {
+	data, err := json.MarshalIndent(rec, "", "\t")
+	if err != nil {
+		return fmt.Errorf("encoding failure record: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("creating directory for %q: %w", path, err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("writing failure record %q: %w", path, err)
+	}
+	return nil
+}
+
+// buildInfo describes the running binary for bug reports.
+func buildInfo() string {
+	var b strings.Builder
+	version, revision, modified := "(devel)", "", false
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if info.Main.Version != "" {
+			version = info.Main.Version
+		}
+		for _, s := range info.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				revision = s.Value
+			case "vcs.modified":
+				modified = s.Value == "true"
+			}
+		}
+	}
+	fmt.Fprintf(&b, "film-cli %s\n", version)
+	if revision != "" {
+		if modified {
+			revision += " (modified)"
+		}
+		fmt.Fprintf(&b, "revision: %s\n", revision)
+	}
+	fmt.Fprintf(&b, "go: %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	return b.String()
+}
+
+// rulesVersion describes the extraction rules in use. They are built into
+// the binary, so its version identifies them; the decode schemes are listed
+// for reports from builds that predate a scheme.
+func rulesVersion() string {
+	return fmt.Sprintf("extraction rules: built-in\ndecode schemes: %s\n", strings.Join(schemeNames(), ", "))
+}
+
+// doctorReport checks what film-cli needs from its environment: the cache
+// backend opens, and a player, ffmpeg (screenshot) and ffprobe (probe) are
+// installed. Each check is one "ok" or "FAIL" line.
+func doctorReport(cfg Config) string {
+	var b strings.Builder
+	check := func(name, detail string, err error) {
+		if err != nil {
+			fmt.Fprintf(&b, "FAIL %s: %v\n", name, err)
+			return
+		}
+		fmt.Fprintf(&b, "ok   %s: %s\n", name, detail)
+	}
+
+	backend := cfg.Cache.Backend
+	if backend == "" {
+		backend = "memory"
+	}
+	_, err := cfg.Cache.Open()
+	check("cache", backend, err)
+	p, err := findPlayer(cfg.Player)
+	check("player", p.Name+" ("+p.Path+")", err)
+	for _, tool := range []string{"ffmpeg", "ffprobe"} {
+		path, err := exec.LookPath(tool)
+		check(tool, path, err)
+	}
+	return b.String()
+}
+
+// safeHeaders are provider headers whose values are kept in reports; the
+// values of all others may be tokens or cookies and are redacted.
+var safeHeaders = []string{"Accept", "Accept-Language", "User-Agent"}
+
+// redactConfig returns cfg with passwords, the status endpoint and provider
+// header values that may carry credentials replaced by redacted. The
+// endpoint can embed a token or name a private host.
+func redactConfig(cfg Config) Config {
+	if cfg.Cache.Redis.Password != "" {
+		cfg.Cache.Redis.Password = redacted
+	}
+	if cfg.Status.Endpoint != "" {
+		cfg.Status.Endpoint = redacted
+	}
+	providers := map[string]ProviderConfig{}
+	for name, p := range cfg.Providers {
+		if len(p.Headers) > 0 {
+			h := map[string]string{}
+			for k, v := range p.Headers {
+				h[k] = redacted
+				for _, safe := range safeHeaders {
+					if http.CanonicalHeaderKey(k) == safe {
+						h[k] = v
+					}
+				}
+			}
+			p.Headers = h
+		}
+		providers[name] = p
+	}
+	cfg.Providers = providers
+	return cfg
+}
+
+func createReport(archivePath, configPath, failurePath string) error {
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		return err
+	}
+	config, err := json.MarshalIndent(redactConfig(cfg), "", "\t")
+	if err != nil {
+		return fmt.Errorf("encoding config: %w", err)
+	}
+
+	f, err := os.Create(archivePath)
+	if err != nil {
+		return fmt.Errorf("creating archive %q: %w", archivePath, err)
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+
+	if err := addBytesToTar(tw, reportVersionName, []byte(buildInfo())); err != nil {
+		return err
+	}
+	if err := addBytesToTar(tw, reportConfigName, append(config, '\n')); err != nil {
+		return err
+	}
+	if err := addBytesToTar(tw, reportRulesName, []byte(rulesVersion())); err != nil {
+		return err
+	}
+	if err := addBytesToTar(tw, reportDoctorName, []byte(doctorReport(cfg))); err != nil {
+		return err
+	}
+	if err := addFileToTar(tw, failurePath, reportFailureName); errors.Is(err, fs.ErrNotExist) {
+		log.Println("No failed resolution recorded; the report has no step log")
+	} else if err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("writing archive %q: %w", archivePath, err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("writing archive %q: %w", archivePath, err)
+	}
+	log.Printf("Wrote report to %s", archivePath)
+	return f.Close()
+}
+
+func addBytesToTar(tw *tar.Writer, name string, data []byte) error {
+	hdr := &tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("archiving %s: %w", name, err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		return fmt.Errorf("archiving %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRedactConfig(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Cache.Redis.Password = "hunter2"
+	cfg.Status = StatusConfig{Share: true, Endpoint: "https://status.internal.test/report?token=abc"}
+	cfg.Providers["vidsrc"] = ProviderConfig{
+		EmbedBase: "https://vidsrc-embed.ru",
+		Headers:   map[string]string{"user-agent": "film-cli", "Cookie": "session=abc", "X-Api-Key": "k"},
+	}
+
+	got := redactConfig(cfg)
+	if got.Cache.Redis.Password != redacted {
+		t.Errorf("redis password = %q, want it redacted", got.Cache.Redis.Password)
+	}
+	if got.Status.Endpoint != redacted || !got.Status.Share {
+		t.Errorf("status = %+v, want the endpoint redacted and sharing kept", got.Status)
+	}
+	h := got.Providers["vidsrc"].Headers
+	if h["user-agent"] != "film-cli" || h["Cookie"] != redacted || h["X-Api-Key"] != redacted {
+		t.Errorf("headers = %v, want only the user agent kept", h)
+	}
+	if got.Providers["vidsrc"].EmbedBase != "https://vidsrc-embed.ru" {
+		t.Errorf("hosts were redacted: %+v", got.Providers["vidsrc"])
+	}
+	if cfg.Providers["vidsrc"].Headers["Cookie"] != "session=abc" {
+		t.Error("redactConfig modified the original config")
+	}
+}
+
+func TestReportIncludesLastFailure(t *testing.T) {
+	if *record {
+		t.Skip("failure fixture is hand-written")
+	}
+	useReplayClient(t, "movie_no_hidden_div")
+	dir := t.TempDir()
+	failurePath := filepath.Join(dir, ".last-failure.json")
+
+	opts := ResolveOptions{IMDBID: "tt0000001", Type: Movie}
+	if _, err := opts.resolveRecorded(failurePath); err == nil {
+		t.Fatal("resolveRecorded succeeded, want the fixture's decode failure")
+	}
+
+	archive := filepath.Join(dir, "report.tar.gz")
+	if err := createReport(archive, "", failurePath); err != nil {
+		t.Fatal(err)
+	}
+	entries := readTarGz(t, archive)
+	if !strings.HasPrefix(entries[reportVersionName], "film-cli ") {
+		t.Errorf("version.txt = %q", entries[reportVersionName])
+	}
+	if !strings.Contains(entries[reportConfigName], `"embed_base"`) {
+		t.Errorf("config.json = %q, want the effective config", entries[reportConfigName])
+	}
+	if rules := entries[reportRulesName]; !strings.Contains(rules, "built-in") || !strings.Contains(rules, "reverse-stride") {
+		t.Errorf("rules-version.txt = %q, want the built-in rules and their schemes", rules)
+	}
+	doctor := entries[reportDoctorName]
+	for _, check := range []string{"cache: memory", "player", "ffmpeg", "ffprobe"} {
+		if !strings.Contains(doctor, check) {
+			t.Errorf("doctor.txt = %q, want a %q check", doctor, check)
+		}
+	}
+
+	var rec failureRecord
+	if err := json.Unmarshal([]byte(entries[reportFailureName]), &rec); err != nil {
+		t.Fatalf("parsing last-failure.json: %v", err)
+	}
+	last := rec.Steps[len(rec.Steps)-1]
+	if rec.IMDBID != "tt0000001" || last.Step != StepDecode || last.Kind != "failed" || rec.ProRCPURL == "" || rec.Error == "" {
+		t.Errorf("failure record = %+v, want the failed decode step and the ProRCP URL", rec)
+	}
+}
+
+func TestResolveRecordedClearsStaleFailure(t *testing.T) {
+	if *record {
+		t.Skip("covered by TestReplayResolveMovie")
+	}
+	useReplayClient(t, "movie_tt0137523")
+	failurePath := filepath.Join(t.TempDir(), ".last-failure.json")
+	if err := writeFailure(failurePath, failureRecord{IMDBID: "tt0000001", Error: "earlier failure"}); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := ResolveOptions{IMDBID: "tt0137523", Type: Movie}
+	if _, err := opts.resolveRecorded(failurePath); err != nil {
+		t.Fatalf("resolveRecorded: %v", err)
+	}
+	if _, err := os.Stat(failurePath); !os.IsNotExist(err) {
+		t.Errorf("failure record survived a successful resolution: %v", err)
+	}
+}
+
+func readTarGz(t *testing.T, path string) map[string]string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gz)
+	entries := map[string]string{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return entries
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries[hdr.Name] = string(data)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Scheme is one known obfuscation of the hidden-div payload on the ProRCP page.
+type Scheme struct {
+	Name   string
+	Decode func(string) (string, error)
+}
+
+// schemes lists every registered decoder, in the order they are preferred
+// when the scheme in use is not known and several decode a payload equally
+// well. Decoders that cannot reject input on their own must come last.
+var schemes = []Scheme{
+	{Name: "reverse-stride", Decode: Deobfuscate},
+	{Name: "caesar3", Decode: deobfuscateCaesar},
+}
+
+func lookupScheme(name string) (Scheme, bool) {
+	for _, s := range schemes {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return Scheme{}, false
+}
+
+// decodePayload tries every registered scheme and returns the most plausible result.
+func decodePayload(payload string) (string, error) {
+	_, out, err := detectScheme(payload)
+	return out, err
+}
+
+// detectScheme is decodePayload that also reports which scheme produced the
+// result. A scheme can accept a payload meant for another and return
+// garbage, so every output is scored with streamURLScore rather than taking
+// the first one that decodes.
+func detectScheme(payload string) (Scheme, string, error) {
+	var errs []string
+	var best Scheme
+	bestOut, bestScore := "", -1
+	for _, s := range schemes {
+		out, err := s.Decode(payload)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", s.Name, err))
+			continue
+		}
+		if score := streamURLScore(out); score > bestScore {
+			best, bestOut, bestScore = s, out, score
+		}
+	}
+	if bestScore < 0 {
+		return Scheme{}, "", fmt.Errorf("no decoder accepted the payload (%s)", strings.Join(errs, "; "))
+	}
+	return best, bestOut, nil
+}
+
+// schemeNames lists the registered scheme names, for usage and error messages.
+func schemeNames() []string {
+	names := make([]string, len(schemes))
+	for i, s := range schemes {
+		names[i] = s.Name
+	}
+	return names
+}
+
+// deobfuscateCaesar undoes the older scheme that shifted every ASCII letter
+// back by three places ("https://" was served as "eqqmp://").
+func deobfuscateCaesar(obfCode string) (string, error) {
+	shifted := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return 'a' + (r-'a'+3)%26
+		case r >= 'A' && r <= 'Z':
+			return 'A' + (r-'A'+3)%26
+		}
+		return r
+	}, obfCode)
+
+	if !strings.HasPrefix(shifted, "http") {
+		return "", fmt.Errorf("shifted output is not a URL")
+	}
+	return shifted, nil
+}
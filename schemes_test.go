@@ -0,0 +1,129 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// The deobfuscation corpus lives in testdata/deobfuscate/<scheme>/, one
+// <case>.in blob per historical page with its expected output in <case>.golden.
+// Run with -update to regenerate the golden files after adding a case.
+var update = flag.Bool("update", false, "rewrite golden files in testdata/deobfuscate")
+
+func TestSchemesGolden(t *testing.T) {
+	for _, s := range schemes {
+		t.Run(s.Name, func(t *testing.T) {
+			inputs, err := filepath.Glob(filepath.Join("testdata", "deobfuscate", s.Name, "*.in"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(inputs) == 0 {
+				t.Fatalf("no corpus for scheme %q; add testdata/deobfuscate/%s/<case>.in", s.Name, s.Name)
+			}
+
+			for _, in := range inputs {
+				name := strings.TrimSuffix(filepath.Base(in), ".in")
+				t.Run(name, func(t *testing.T) {
+					blob, err := os.ReadFile(in)
+					if err != nil {
+						t.Fatal(err)
+					}
+					got, err := s.Decode(strings.TrimSpace(string(blob)))
+					if err != nil {
+						t.Fatalf("decode: %v", err)
+					}
+
+					golden := strings.TrimSuffix(in, ".in") + ".golden"
+					if *update {
+						if err := os.WriteFile(golden, []byte(got+"\n"), 0644); err != nil {
+							t.Fatal(err)
+						}
+					}
+					want, err := os.ReadFile(golden)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if got != strings.TrimSuffix(string(want), "\n") {
+						t.Errorf("decoded output differs from %s", golden)
+					}
+				})
+			}
+		})
+	}
+}
+
+// Auto-detection must route every corpus blob to a scheme that decodes it
+// to the golden output, whichever scheme produced it.
+func TestDecodePayloadCorpus(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "deobfuscate", "*", "*.in"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, in := range inputs {
+		blob, err := os.ReadFile(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := os.ReadFile(strings.TrimSuffix(in, ".in") + ".golden")
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := decodePayload(strings.TrimSpace(string(blob)))
+		if err != nil {
+			t.Errorf("%s: %v", in, err)
+			continue
+		}
+		if got != strings.TrimSuffix(string(want), "\n") {
+			t.Errorf("%s: auto-detected output differs from golden", in)
+		}
+	}
+}
+
+func TestDeobfuscateWith(t *testing.T) {
+	blob, err := os.ReadFile(filepath.Join("testdata", "deobfuscate", "caesar3", "test-html.in"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := strings.TrimSpace(string(blob))
+
+	auto, err := deobfuscateWith("auto", payload)
+	if err != nil {
+		t.Fatalf("auto: %v", err)
+	}
+	named, err := deobfuscateWith("caesar3", payload)
+	if err != nil || named != auto {
+		t.Errorf("caesar3 = %q, %v; want the auto-detected output", named, err)
+	}
+	if _, err := deobfuscateWith("reverse-stride", payload); err == nil {
+		t.Error("reverse-stride accepted a caesar3 payload")
+	}
+	if _, err := deobfuscateWith("rot13", payload); err == nil {
+		t.Error("unknown scheme accepted")
+	}
+}
+
+func TestDetectSchemePrefersPlausibleOutput(t *testing.T) {
+	// Every other rune of this caesar3 payload happens to be valid base64,
+	// so reverse-stride accepts it too and returns binary garbage.
+	want := "https://cdn.example.test/pl/1080/master.mp4"
+	payload := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' {
+			return 'a' + (r-'a'+23)%26
+		}
+		return r
+	}, want)
+	if _, err := Deobfuscate(payload); err != nil {
+		t.Fatalf("reverse-stride rejected the payload (%v); the test needs one it accepts", err)
+	}
+
+	s, got, err := detectScheme(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Name != "caesar3" || got != want {
+		t.Errorf("detectScheme = %s, %q; want caesar3, %q", s.Name, got, want)
+	}
+}
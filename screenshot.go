@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func runScreenshot(args []string) error {
+	flags := flag.NewFlagSet("screenshot", flag.ExitOnError)
+	media := addMediaFlags(flags)
+	at := flags.String("at", "00:10:00", "position of the frame, as HH:MM:SS or seconds")
+	out := flags.String("o", "", "image to write (default <imdb-id>-<position>.jpg)")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return errors.New(msg(msgScreenshotUsage))
+	}
+
+	pos, err := parseTimestamp(*at)
+	if err != nil {
+		return err
+	}
+	if _, err := loadConfig(); err != nil {
+		return err
+	}
+
+	opts := media.options(flags.Arg(0))
+	master, err := opts.resolveRecorded(lastFailurePath())
+	if err != nil {
+		return err
+	}
+
+	path := *out
+	if path == "" {
+		path = fmt.Sprintf("%s-%s.jpg", opts.IMDBID, strings.ReplaceAll(formatTimestamp(pos), ":", "-"))
+	}
+	if err := grabFrame(master.bestVariant().URL, pos, path); err != nil {
+		return err
+	}
+	fmt.Println(msg(msgScreenshotSaved, path))
+	return nil
+}
+
+// grabFrame has ffmpeg seek into the stream and write the frame at pos to
+// path. Seeking before the input makes ffmpeg fetch only the segments around
+// pos rather than the whole stream.
+func grabFrame(streamURL string, pos time.Duration, path string) error {
+	ffmpeg, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		return fmt.Errorf("ffmpeg is required for screenshots: %w", err)
+	}
+
+	args := []string{"-hide_banner", "-loglevel", "error", "-y"}
+	if h := ffmpegHeaders(vidsrc.cdnHeaders()); h != "" {
+		args = append(args, "-headers", h)
+	}
+	args = append(args,
+		"-ss", strconv.FormatFloat(pos.Seconds(), 'f', -1, 64),
+		"-i", streamURL,
+		"-frames:v", "1", "-q:v", "2",
+		path)
+
+	cmd := exec.Command(ffmpeg, args...)
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("running ffmpeg: %w", err)
+	}
+	return nil
+}
+
+// ffmpegHeaders formats headers for ffmpeg's -headers option.
+func ffmpegHeaders(headers map[string]string) string {
+	keys := make([]string, 0, len(headers))
+	for k := range headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		fmt.Fprintf(&b, "%s: %s\r\n", k, headers[k])
+	}
+	return b.String()
+}
+
+// parseTimestamp reads a position given as HH:MM:SS, MM:SS or plain seconds.
+func parseTimestamp(s string) (time.Duration, error) {
+	parts := strings.Split(strings.TrimSpace(s), ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("invalid timestamp %q: want HH:MM:SS or seconds", s)
+	}
+	var total float64
+	for i, p := range parts {
+		v, err := strconv.ParseFloat(p, 64)
+		if err != nil || v < 0 || (i > 0 && v >= 60) {
+			return 0, fmt.Errorf("invalid timestamp %q: want HH:MM:SS or seconds", s)
+		}
+		total = total*60 + v
+	}
+	return secondsDuration(total), nil
+}
+
+// secondsDuration converts a duration in seconds, as ffprobe and playlists
+// report it, to a time.Duration.
+func secondsDuration(sec float64) time.Duration {
+	return time.Duration(sec * float64(time.Second))
+}
+
+// formatTimestamp writes d as HH:MM:SS, dropping fractions of a second.
+func formatTimestamp(d time.Duration) string {
+	sec := int(d / time.Second)
+	return fmt.Sprintf("%02d:%02d:%02d", sec/3600, sec/60%60, sec%60)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTimestamp(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+		ok   bool
+	}{
+		{"00:42:00", 42 * time.Minute, true},
+		{"1:02:03", time.Hour + 2*time.Minute + 3*time.Second, true},
+		{"05:30", 5*time.Minute + 30*time.Second, true},
+		{"90", 90 * time.Second, true},
+		{"12.5", 12500 * time.Millisecond, true},
+		{"00:61:00", 0, false},
+		{"1:2:3:4", 0, false},
+		{"soon", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := parseTimestamp(tt.in)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("parseTimestamp(%q) = %v, %v; want %v, ok=%v", tt.in, got, err, tt.want, tt.ok)
+		}
+	}
+	if got := formatTimestamp(time.Hour + 2*time.Minute + 3500*time.Millisecond); got != "01:02:03" {
+		t.Errorf("formatTimestamp = %q, want 01:02:03", got)
+	}
+}
+
+func TestFFmpegHeaders(t *testing.T) {
+	got := ffmpegHeaders(map[string]string{"Referer": "https://a.test/", "Origin": "https://a.test"})
+	if want := "Origin: https://a.test\r\nReferer: https://a.test/\r\n"; got != want {
+		t.Errorf("ffmpegHeaders = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+// StatusConfig controls sharing whether resolutions work with a community
+// status endpoint. Nothing is sent unless Share is set and Endpoint names
+// a server; there is no built-in endpoint.
+type StatusConfig struct {
+	Share    bool   `json:"share"`
+	Endpoint string `json:"endpoint"`
+}
+
+// status sharing settings, replaced from the config file in main
+var statusSharing StatusConfig
+
+// statusTimeout bounds a status report so a slow endpoint cannot hold up
+// the CLI.
+const statusTimeout = 3 * time.Second
+
+// statusReport is everything that is shared: the provider name and
+// whether resolving with it worked. No titles, URLs or errors are sent.
+type statusReport struct {
+	Provider string `json:"provider"`
+	OK       bool   `json:"ok"`
+}
+
+// shareStatus reports the outcome of a resolution when sharing is enabled.
+// Failures to report are logged and otherwise ignored.
+func (c StatusConfig) shareStatus(ok bool) {
+	if !c.Share || c.Endpoint == "" {
+		return
+	}
+	if err := c.post(statusReport{Provider: "vidsrc", OK: ok}); err != nil {
+		log.Printf("Failed to share provider status: %v", err)
+	}
+}
+
+func (c StatusConfig) post(r statusReport) error {
+	body, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("encoding status report: %w", err)
+	}
+	ctx, cancel := stepContext(Duration(statusTimeout))
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating request for %q: %w", c.Endpoint, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("posting status to %q: %w", c.Endpoint, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return &statusError{Code: resp.StatusCode, What: "status endpoint", URL: c.Endpoint}
+	}
+	return nil
+}
+
+// statusFeed is what the status endpoint serves on GET: the number of
+// recent reports per provider.
+type statusFeed struct {
+	Providers map[string]providerCounts `json:"providers"`
+}
+
+type providerCounts struct {
+	OK     int `json:"ok"`
+	Failed int `json:"failed"`
+}
+
+// minStatusReports is how many recent reports the feed needs before its
+// numbers say anything.
+const minStatusReports = 5
+
+// verdict returns the message key that interprets c: a provider failing for
+// at least half of its users is broken for everyone.
+func (c providerCounts) verdict() string {
+	switch total := c.OK + c.Failed; {
+	case total < minStatusReports:
+		return msgStatusUnknown
+	case c.Failed*2 >= total:
+		return msgStatusWidespread
+	default:
+		return msgStatusLocal
+	}
+}
+
+func (c StatusConfig) fetchFeed() (statusFeed, error) {
+	var feed statusFeed
+	if c.Endpoint == "" {
+		return feed, fmt.Errorf("no status endpoint configured; set status.endpoint in the config")
+	}
+	ctx, cancel := stepContext(Duration(statusTimeout))
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", c.Endpoint, nil)
+	if err != nil {
+		return feed, fmt.Errorf("creating request for %q: %w", c.Endpoint, err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return feed, fmt.Errorf("fetching status feed %q: %w", c.Endpoint, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return feed, &statusError{Code: resp.StatusCode, What: "status feed", URL: c.Endpoint}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&feed); err != nil {
+		return feed, fmt.Errorf("parsing status feed %q: %w", c.Endpoint, err)
+	}
+	return feed, nil
+}
+
+// runStatus prints the last local failure and, with -remote, what the
+// community status feed says about the provider.
+func runStatus(args []string) error {
+	flags := flag.NewFlagSet("status", flag.ExitOnError)
+	remote := flags.Bool("remote", false, "check the community status feed to tell widespread outages from local problems")
+	flags.Parse(args)
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(lastFailurePath())
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		fmt.Println(msg(msgNoLastFailure))
+	case err != nil:
+		return fmt.Errorf("reading last failure: %w", err)
+	default:
+		var rec failureRecord
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return fmt.Errorf("parsing last failure: %w", err)
+		}
+		fmt.Println(msg(msgLastFailure, rec.Time.Local().Format(time.DateTime), rec.IMDBID, rec.Error))
+	}
+
+	if !*remote {
+		return nil
+	}
+	feed, err := cfg.Status.fetchFeed()
+	if err != nil {
+		return err
+	}
+	c := feed.Providers["vidsrc"]
+	fmt.Println(msg(msgRemoteStatus, "vidsrc", c.OK, c.OK+c.Failed))
+	fmt.Println(msg(c.verdict()))
+	return nil
+}
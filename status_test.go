@@ -0,0 +1,62 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestShareStatus(t *testing.T) {
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got = append(got, r.Method+" "+string(body))
+	}))
+	defer srv.Close()
+
+	StatusConfig{Share: false, Endpoint: srv.URL}.shareStatus(true)
+	StatusConfig{Share: true}.shareStatus(true)
+	if len(got) != 0 {
+		t.Fatalf("sent %q without opting in and an endpoint", got)
+	}
+
+	StatusConfig{Share: true, Endpoint: srv.URL}.shareStatus(false)
+	if want := `POST {"provider":"vidsrc","ok":false}`; len(got) != 1 || got[0] != want {
+		t.Errorf("sent %q, want only %q", got, want)
+	}
+}
+
+func TestStatusFeedVerdict(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"providers": {"vidsrc": {"ok": 3, "failed": 37}}}`)
+	}))
+	defer srv.Close()
+
+	feed, err := StatusConfig{Endpoint: srv.URL}.fetchFeed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := feed.Providers["vidsrc"]; c != (providerCounts{OK: 3, Failed: 37}) || c.verdict() != msgStatusWidespread {
+		t.Errorf("vidsrc = %+v (%s), want a widespread outage", c, c.verdict())
+	}
+
+	tests := []struct {
+		c    providerCounts
+		want string
+	}{
+		{providerCounts{}, msgStatusUnknown},
+		{providerCounts{OK: 1, Failed: 2}, msgStatusUnknown},
+		{providerCounts{OK: 40, Failed: 3}, msgStatusLocal},
+		{providerCounts{OK: 5, Failed: 5}, msgStatusWidespread},
+	}
+	for _, tt := range tests {
+		if got := tt.c.verdict(); got != tt.want {
+			t.Errorf("%+v.verdict() = %s, want %s", tt.c, got, tt.want)
+		}
+	}
+
+	if _, err := (StatusConfig{}).fetchFeed(); err == nil {
+		t.Error("fetchFeed succeeded without an endpoint")
+	}
+}